@@ -0,0 +1,337 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// AnswerSource gives the answers to the questions, to run them without a
+// terminal. The key of a question is its prompt, or the path of the field in
+// AskStruct, as "DB.Driver".
+type AnswerSource interface {
+	// Returns the answer for the key, and false if there is none.
+	Answer(key string) (answer string, ok bool)
+}
+
+// === Sources
+// ===
+
+// MapAnswers gives the answers from a map.
+type MapAnswers map[string]string
+
+func (m MapAnswers) Answer(key string) (string, bool) {
+	answer, ok := m[key]
+	return answer, ok
+}
+
+// EnvAnswers gives the answers from environment variables whose names are
+// the prefix plus the key in upper case, where the characters which are not
+// letters or digits are replaced by '_'. Then, with the prefix "APP_", the key
+// "DB.Driver" is got from $APP_DB_DRIVER.
+type EnvAnswers string
+
+func (prefix EnvAnswers) Answer(key string) (string, bool) {
+	return os.LookupEnv(string(prefix) + envName(key))
+}
+
+// Returns the key as part of the name of a variable.
+func envName(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.ToUpper(strings.Join(words, "_"))
+}
+
+// Reads the answers from a file with lines "key=value", as the ones written
+// by Question.Record. The key and the value can be quoted as Go strings, as
+// it is done by the recording when they could not be read back as they are.
+// The empty lines and the ones starting with '#' are skipped, and a key
+// repeated is set to its last value.
+func LoadAnswers(filename string) (MapAnswers, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	answers := make(MapAnswers)
+	scanner := bufio.NewScanner(file)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		key, value, ok := parseAnswer(line)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: the line is not \"key=value\"", filename, n)
+		}
+		answers[key] = value
+	}
+	return answers, scanner.Err()
+}
+
+// Returns the key and the value of a line "key=value", unquoting them if they
+// are quoted.
+func parseAnswer(line string) (key, value string, ok bool) {
+	if strings.HasPrefix(line, `"`) {
+		quoted, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return "", "", false
+		}
+		key, _ = strconv.Unquote(quoted)
+		line = strings.TrimSpace(line[len(quoted):])
+
+		if !strings.HasPrefix(line, "=") {
+			return "", "", false
+		}
+		value = line[1:]
+	} else {
+		eq := strings.IndexByte(line, '=')
+		if eq == -1 {
+			return "", "", false
+		}
+		key, value = strings.TrimSpace(line[:eq]), line[eq+1:]
+	}
+
+	if value = strings.TrimSpace(value); strings.HasPrefix(value, `"`) {
+		var err error
+		if value, err = strconv.Unquote(value); err != nil {
+			return "", "", false
+		}
+	}
+	return key, value, true
+}
+
+// Returns the text quoted as a Go string if it could not be read back as it
+// is: when it has some character of 'special', spaces at the ends, or it
+// starts with a quote.
+func quoteAnswer(s, special string) string {
+	if s != strings.TrimSpace(s) || strings.HasPrefix(s, `"`) ||
+		strings.ContainsAny(s, special+"\n\r") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Returns the values separated by commas, quoting the ones which have commas.
+func joinValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quoteAnswer(v, ",")
+	}
+	return strings.Join(quoted, ",")
+}
+
+// Splits the values separated by commas, unquoting the ones which are quoted.
+func splitValues(s string) (values []string, ok bool) {
+	for {
+		s = strings.TrimLeft(s, " ")
+		var value string
+
+		if strings.HasPrefix(s, `"`) {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+
+			if s = strings.TrimLeft(s[len(quoted):], " "); s != "" && s[0] != ',' {
+				return nil, false
+			}
+		} else {
+			if comma := strings.IndexByte(s, ','); comma != -1 {
+				value, s = s[:comma], s[comma:]
+			} else {
+				value, s = s, ""
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		values = append(values, value)
+		if s == "" {
+			return values, true
+		}
+		s = s[1:] // Comma
+	}
+}
+
+// === Input
+// ===
+
+// Reason to reject an empty answer when there is none by default.
+var errEmptyAnswer = fmt.Errorf("the answer is empty")
+
+// questionInput reads the answers of a question, from the terminal or from
+// the source of answers.
+type questionInput struct {
+	q      *Question
+	line   *Line // Terminal; nil when it is used the source
+	key    string
+	hasDef bool // There is an answer by default
+
+	answer string // Last answer read
+	read   bool   // The answer was got from the source
+	reason error  // Why the last answer was rejected
+}
+
+// Returns the input for a question.
+func (q *Question) input(prompt, defaultAnswer string, def hasDefault) *questionInput {
+	in := &questionInput{
+		q:      q,
+		key:    q.answerKey(prompt),
+		hasDef: def != _DEFAULT_NO,
+	}
+	if q.Answers == nil {
+		in.line = q.getLine(prompt, defaultAnswer, def)
+	}
+	return in
+}
+
+// Reads an answer. From the source, it is returned an empty answer, to use the
+// one by default, when there is none; and a second read fails since the first
+// answer was wrong.
+func (in *questionInput) Read() (string, error) {
+	if in.line != nil {
+		answer, err := in.line.Read()
+		in.answer = answer
+		return answer, err
+	}
+
+	if in.read {
+		if in.reason != nil {
+			return "", fmt.Errorf("question %q: wrong answer %q: %v", in.key, in.answer, in.reason)
+		}
+		return "", fmt.Errorf("question %q: wrong answer %q", in.key, in.answer)
+	}
+	in.read = true
+
+	answer, ok := in.q.Answers.Answer(in.key)
+	if !ok && !in.hasDef {
+		return "", fmt.Errorf("question %q: no answer", in.key)
+	}
+	in.answer = strings.TrimSpace(answer)
+	return in.answer, nil
+}
+
+// Prints why the last answer was rejected, before of asking again.
+func (in *questionInput) invalid(err error) {
+	printInvalid(in.answer, err)
+	in.reject(err)
+}
+
+// Keeps why the last answer was rejected, to return it when the answer is got
+// from the source.
+func (in *questionInput) reject(err error) {
+	in.reason = err
+}
+
+// Writes the last answer given at the terminal, if it is set Question.Record
+// and there is no error.
+func (in *questionInput) record(err *error) {
+	if *err == nil && in.line != nil {
+		in.q.recordAnswer(in.key, in.answer)
+	}
+}
+
+// Writes the answer if it is set Question.Record. The key and the answer are
+// quoted when it is necessary to read them by LoadAnswers.
+func (q *Question) recordAnswer(key, answer string) {
+	if q.Record == nil {
+		return
+	}
+	if strings.HasPrefix(key, "#") {
+		key = strconv.Quote(key)
+	} else {
+		key = quoteAnswer(key, "=")
+	}
+	fmt.Fprintf(q.Record, "%s=%s\n", key, quoteAnswer(answer, ""))
+}
+
+// Returns the position of the option answered by the source. If there is no
+// answer, it is returned the one by default, if 'hasDef' is true.
+func (q *Question) answerSelect(key string, options []string, def int, hasDef bool) (int, error) {
+	answer, ok := q.Answers.Answer(key)
+	if !ok {
+		if !hasDef {
+			return -1, fmt.Errorf("question %q: no answer", key)
+		}
+		return def, nil
+	}
+
+	answer = strings.TrimSpace(answer)
+	for i, option := range options {
+		if option == answer {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("question %q: wrong answer %q", key, answer)
+}
+
+// Returns the positions of the options answered by the source, separated by
+// commas, and quoted if they have commas. If there is no answer, they are
+// returned the ones checked.
+func (q *Question) answerMultiSelect(key string, options []string, checked []int, min, max int) ([]int, error) {
+	var values []string
+
+	if answer, ok := q.Answers.Answer(key); ok {
+		if values, ok = splitValues(answer); !ok {
+			return nil, fmt.Errorf("question %q: wrong answer %q", key, answer)
+		}
+	} else {
+		for _, i := range checked {
+			values = append(values, options[i])
+		}
+	}
+
+	marked := make([]bool, len(options))
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+
+		found := false
+		for i, option := range options {
+			if option == value {
+				marked[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("question %q: wrong answer %q", key, value)
+		}
+	}
+
+	selected := make([]int, 0)
+	for i, ok := range marked {
+		if ok {
+			selected = append(selected, i)
+		}
+	}
+	if len(selected) < min || max != 0 && len(selected) > max {
+		return nil, fmt.Errorf("question %q: %d options answered", key, len(selected))
+	}
+	return selected, nil
+}
+
+// Returns the key of the answer of a question.
+func (q *Question) answerKey(prompt string) string {
+	if q.key != "" {
+		return q.key
+	}
+	return prompt
+}
@@ -0,0 +1,139 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnswers(t *testing.T) {
+	discardOutput(t)
+
+	q := NewQuestion()
+	q.Answers = MapAnswers{
+		"Host":       "example.org",
+		"Debug":      "yes",
+		"DB.Driver":  "postgres",
+		"DB.Name":    "app",
+		"Cache.Name": "cache",
+		"Color":      "blue",
+		"Features":   "tests, docs",
+	}
+
+	var cfg formConfig
+	if err := q.AskStruct(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "example.org" || cfg.Port != 8080 || !cfg.Debug ||
+		cfg.DB.Driver != "postgres" || cfg.DB.Name != "app" ||
+		cfg.Cache.Driver != "mysql" || cfg.Cache.Name != "cache" {
+		t.Errorf("AskStruct: got %+v, cache %+v", cfg, *cfg.Cache)
+	}
+
+	// The strings which represent numbers are strings.
+	var version struct {
+		Version string `default:"1.0"`
+		Zip     string
+	}
+	q.Answers = MapAnswers{"Version": "2.0", "Zip": "02139"}
+	if err := q.AskStruct(&version); err != nil || version.Version != "2.0" || version.Zip != "02139" {
+		t.Errorf("AskStruct of numeric strings: got %+v, %v", version, err)
+	}
+	q.Answers = MapAnswers{
+		"Color":    "blue",
+		"Features": "tests, docs",
+	}
+
+	if i, value, err := q.Select("Color", []string{"red", "blue"}); err != nil || i != 1 || value != "blue" {
+		t.Errorf("Select: got %d, %q, %v", i, value, err)
+	}
+	if i, _, err := q.SelectDefault("Size", []string{"s", "m"}, 1); err != nil || i != 1 {
+		t.Errorf("SelectDefault without answer: got %d, %v", i, err)
+	}
+	if sel, err := q.MultiSelect("Features", []string{"docs", "tests", "examples"}, nil, 1, 0); err != nil || len(sel) != 2 || sel[0] != 0 || sel[1] != 1 {
+		t.Errorf("MultiSelect: got %v, %v", sel, err)
+	}
+
+	// === Errors
+	_, err := q.ReadString("Missing")
+	if err == nil || !strings.Contains(err.Error(), "no answer") {
+		t.Errorf("missing answer: got error %v", err)
+	}
+	if answer, err := q.ReadStringDefault("Missing", "def"); err != nil || answer != "def" {
+		t.Errorf("answer by default: got %q, %v", answer, err)
+	}
+	// The reason of a wrong answer is returned.
+	q.Answers = MapAnswers{"Port": "http", "Level": "high", "Name": ""}
+	for _, tt := range []struct {
+		ask    func() error
+		reason string
+	}{
+		{func() error { _, err := q.ReadInt("Port"); return err }, "has to be an integer"},
+		{func() error { _, err := q.ReadChoice("Level", []string{"low"}); return err }, "is not in"},
+		{func() error { _, err := q.Read("Name"); return err }, "is empty"},
+	} {
+		if err = tt.ask(); err == nil || !strings.Contains(err.Error(), "wrong answer") ||
+			!strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("wrong answer: got error %v, want reason %q", err, tt.reason)
+		}
+	}
+
+	// === Environment
+	os.Setenv("APP_DB_DRIVER", "mysql")
+	defer os.Unsetenv("APP_DB_DRIVER")
+	if answer, ok := EnvAnswers("APP_").Answer("DB.Driver"); !ok || answer != "mysql" {
+		t.Errorf("EnvAnswers: got %q, %v", answer, ok)
+	}
+	if envName("Debug mode?") != "DEBUG_MODE" {
+		t.Errorf("envName: got %q", envName("Debug mode?"))
+	}
+
+	// === Recording and file
+	var buf bytes.Buffer
+	q.Record = &buf
+	recorded := MapAnswers{
+		"Host":           "example.org",
+		"DB.Name":        "a=b",
+		"Level (1=low)?": "1",
+		"# of users":     " 10 ",
+		`"Quoted"`:       `"value"`,
+		"Tags":           joinValues([]string{"a,b", "c"}),
+	}
+	for key, answer := range recorded {
+		q.recordAnswer(key, answer)
+	}
+
+	fname := historyFile + "_answers"
+	defer os.Remove(fname)
+	ioutil.WriteFile(fname, []byte("# Answers\n\n"+buf.String()), 0600)
+
+	answers, err := LoadAnswers(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(answers, recorded) {
+		t.Errorf("LoadAnswers: got %q, want %q", answers, recorded)
+	}
+
+	q.Answers = answers
+	if sel, err := q.MultiSelect("Tags", []string{"a,b", "c", "d"}, nil, 0, 0); err != nil || len(sel) != 2 || sel[0] != 0 || sel[1] != 1 {
+		t.Errorf("MultiSelect of values with commas: got %v, %v", sel, err)
+	}
+
+	ioutil.WriteFile(fname, []byte("Host\n"), 0600)
+	if _, err = LoadAnswers(fname); err == nil {
+		t.Error("LoadAnswers: expected error for a line without '='")
+	}
+}
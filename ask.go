@@ -0,0 +1,212 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Converts an answer to a value. The error is the message printed before of
+// asking again, after of QuestionErrPrefix.
+type Parser[T any] func(answer string) (T, error)
+
+// Converts a value to the text shown as answer by default.
+type Formatter[T any] func(value T) string
+
+// Prints the prompt waiting to get an answer that is converted by 'parse', and
+// which passes the validators. Else, it is printed the message of the parser
+// or the first validator that fails, and it is asked again.
+func Ask[T any](q *Question, prompt string, parse Parser[T], valid ...Validator[T]) (answer T, err error) {
+	return _baseAsk(q, prompt, parse, nil, answer, _DEFAULT_NO, valid)
+}
+
+// Prints the prompt waiting to get an answer that is converted by 'parse'.
+// If input is nil then it returns the answer by default, which is not checked.
+// It is shown as the text returned by 'format', or by fmt.Sprint if it is nil.
+func AskDefault[T any](q *Question, prompt string, parse Parser[T], format Formatter[T], defaultAnswer T, valid ...Validator[T]) (answer T, err error) {
+	return _baseAsk(q, prompt, parse, format, defaultAnswer, _DEFAULT_SIMPLE, valid)
+}
+
+// Base to ask values of any type.
+func _baseAsk[T any](q *Question, prompt string, parse Parser[T], format Formatter[T], defaultAnswer T, def hasDefault, valid []Validator[T]) (answer T, err error) {
+	var none T
+	var defText string
+
+	if def != _DEFAULT_NO {
+		if format != nil {
+			defText = format(defaultAnswer)
+		} else {
+			defText = fmt.Sprint(defaultAnswer)
+		}
+	}
+
+	line := q.input(prompt, defText, def)
+	defer line.record(&err)
+
+	for {
+		input, err := line.Read()
+		if err != nil {
+			return none, err
+		}
+		if q.isBack(input) {
+			return none, errGoBack
+		}
+		if input == "" {
+			if def != _DEFAULT_NO {
+				return defaultAnswer, nil
+			}
+			line.reject(errEmptyAnswer)
+			continue
+		}
+
+		if answer, err = parse(input); err != nil {
+			line.invalid(err)
+			continue
+		}
+		if err = validate(answer, valid); err != nil {
+			line.invalid(err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// === Parsers
+// ===
+
+// Signed integer types.
+type signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Unsigned integer types.
+type unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Number types.
+type number interface {
+	signed | unsigned | ~float32 | ~float64
+}
+
+// Parses a string which does not represent a number.
+func ParseString(answer string) (string, error) {
+	if _, err := strconv.ParseFloat(answer, 64); err == nil {
+		return "", fmt.Errorf("the value has to be a string")
+	}
+	return answer, nil
+}
+
+// Returns the answer as it is.
+func parseText(answer string) (string, error) {
+	return answer, nil
+}
+
+// Parses an integer number of type T.
+func ParseInt[T signed](answer string) (T, error) {
+	n, err := strconv.ParseInt(answer, 10, reflect.TypeOf(T(0)).Bits())
+	if err != nil {
+		return 0, numError(err, "an integer")
+	}
+	return T(n), nil
+}
+
+// Parses an integer number not negative of type T.
+func ParseUint[T unsigned](answer string) (T, error) {
+	n, err := strconv.ParseUint(answer, 10, reflect.TypeOf(T(0)).Bits())
+	if err != nil {
+		return 0, numError(err, "an unsigned integer")
+	}
+	return T(n), nil
+}
+
+// Parses a float number.
+func ParseFloat(answer string) (float64, error) {
+	n, err := strconv.ParseFloat(answer, 64)
+	if err != nil {
+		return 0, numError(err, "a float")
+	}
+	return n, nil
+}
+
+// Parses an integer number of any size.
+func ParseBigInt(answer string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(answer, 10)
+	if !ok {
+		return nil, fmt.Errorf("the value has to be an integer")
+	}
+	return n, nil
+}
+
+// Parses a duration, as "1h30m".
+func ParseDuration(answer string) (time.Duration, error) {
+	d, err := time.ParseDuration(answer)
+	if err != nil {
+		return 0, fmt.Errorf(`the value has to be a duration, as "1h30m"`)
+	}
+	return d, nil
+}
+
+// Parses an IPv4 or IPv6 address.
+func ParseIP(answer string) (net.IP, error) {
+	ip := net.ParseIP(answer)
+	if ip == nil {
+		return nil, fmt.Errorf("the value has to be an IP address")
+	}
+	return ip, nil
+}
+
+// Parses an absolute URL, that is to say, one with scheme.
+func ParseURL(answer string) (*url.URL, error) {
+	u, err := url.Parse(answer)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf(`the value has to be a URL, as "http://example.org"`)
+	}
+	return u, nil
+}
+
+// Returns a parser of times in the format given by 'layout', as in time.Parse.
+func TimeParser(layout string) Parser[time.Time] {
+	return func(answer string) (time.Time, error) {
+		t, err := time.Parse(layout, answer)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("the value has to be a time as %q", layout)
+		}
+		return t, nil
+	}
+}
+
+// Returns a formatter of times in the format given by 'layout'.
+func TimeFormatter(layout string) Formatter[time.Time] {
+	return func(t time.Time) string { return t.Format(layout) }
+}
+
+// ===
+
+// Returns the message for an error of strconv, where 'kind' is the type of
+// value expected.
+func numError(err error, kind string) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("the value is out of range")
+	}
+	return fmt.Errorf("the value has to be %s", kind)
+}
+
+// Formats a float number with QuestionFloatFmt and QuestionFloatPrec.
+func formatFloat(n float64) string {
+	return strconv.FormatFloat(n, QuestionFloatFmt, QuestionFloatPrec, 64)
+}
@@ -0,0 +1,96 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		parse  func(string) (interface{}, error)
+		answer string
+		ok     bool
+	}{
+		{anyParser(ParseString), "text", true},
+		{anyParser(ParseString), "1.5", false},
+		{anyParser(ParseInt[int8]), "-128", true},
+		{anyParser(ParseInt[int8]), "128", false},
+		{anyParser(ParseInt[int]), "one", false},
+		{anyParser(ParseUint[uint16]), "65535", true},
+		{anyParser(ParseUint[uint16]), "-1", false},
+		{anyParser(ParseFloat), "2.5e3", true},
+		{anyParser(ParseBigInt), "123456789012345678901234567890", true},
+		{anyParser(ParseBigInt), "12a", false},
+		{anyParser(ParseDuration), "1h30m", true},
+		{anyParser(ParseDuration), "90", false},
+		{anyParser(ParseIP), "::1", true},
+		{anyParser(ParseIP), "10.0.0", false},
+		{anyParser(ParseURL), "http://example.org/a", true},
+		{anyParser(ParseURL), "example.org", false},
+		{anyParser(TimeParser("2006-01-02")), "2010-11-30", true},
+		{anyParser(TimeParser("2006-01-02")), "30/11/2010", false},
+	}
+
+	for i, tt := range tests {
+		if _, err := tt.parse(tt.answer); (err == nil) != tt.ok {
+			t.Errorf("%d. %q: got error %v", i, tt.answer, err)
+		}
+	}
+
+	if s := TimeFormatter("2006-01-02")(time.Date(2010, 11, 30, 0, 0, 0, 0, time.UTC)); s != "2010-11-30" {
+		t.Errorf("TimeFormatter: got %q", s)
+	}
+}
+
+func TestAsk(t *testing.T) {
+	discardOutput(t)
+
+	q := NewQuestion()
+	q.Answers = MapAnswers{
+		"Timeout": "1m",
+		"Address": "192.168.1.1",
+		"Size":    "4096",
+		"Port":    "80",
+	}
+
+	if d, err := Ask(q, "Timeout", ParseDuration); err != nil || d != time.Minute {
+		t.Errorf("Ask duration: got %v, %v", d, err)
+	}
+	if ip, err := Ask(q, "Address", ParseIP); err != nil || !ip.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Ask IP: got %v, %v", ip, err)
+	}
+	if n, err := Ask(q, "Size", ParseBigInt); err != nil || n.Cmp(big.NewInt(4096)) != 0 {
+		t.Errorf("Ask big.Int: got %v, %v", n, err)
+	}
+
+	day := time.Date(2010, 11, 30, 0, 0, 0, 0, time.UTC)
+	if d, err := AskDefault(q, "Day", TimeParser("2006-01-02"), TimeFormatter("2006-01-02"), day); err != nil || !d.Equal(day) {
+		t.Errorf("AskDefault time: got %v, %v", d, err)
+	}
+
+	// The answer fails the validator, so it is asked again.
+	if _, err := Ask(q, "Port", ParseUint[uint16], func(n uint16) error {
+		if n < 1024 {
+			return os.ErrInvalid
+		}
+		return nil
+	}); err == nil {
+		t.Error("Ask with validator: expected error")
+	}
+}
+
+func anyParser[T any](parse Parser[T]) func(string) (interface{}, error) {
+	return func(answer string) (interface{}, error) { return parse(answer) }
+}
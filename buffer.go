@@ -38,6 +38,14 @@ type buffer struct {
 	pos        int    // Pointer position into buffer
 	size       int    // Amount of characters added
 	data       []rune // Text buffer
+
+	undo []bufState // Previous states of the text, to undo changes
+}
+
+// Represents the state of the text, after of the prompt.
+type bufState struct {
+	pos  int
+	text []rune
 }
 
 func newBuffer(promptLen int) *buffer {
@@ -94,6 +102,34 @@ func (b *buffer) insertRunes(runes []rune) error {
 	return nil
 }
 
+// Inserts several characters at once, refreshing the line only one time.
+// The characters that do not fit into the buffer capacity are discarded.
+func (b *buffer) insertText(text []rune) error {
+	if free := cap(b.data) - b.size; len(text) > free {
+		text = text[:free]
+	}
+	if len(text) == 0 {
+		return nil
+	}
+
+	b.grow(b.size + len(text))
+	copy(b.data[b.pos+len(text):b.size+len(text)], b.data[b.pos:b.size])
+	copy(b.data[b.pos:], text)
+
+	atEnd := b.pos == b.size
+	b.pos += len(text)
+	b.size += len(text)
+
+	// Avoid a full update of the line.
+	if atEnd {
+		if _, err := output.Write([]byte(string(text))); err != nil {
+			return outputError(err.Error())
+		}
+		return nil
+	}
+	return b.refresh()
+}
+
 // Returns a slice of the contents of the buffer.
 func (b *buffer) toBytes() []byte {
 	chars := make([]byte, b.size*utf8.UTFMax)
@@ -117,8 +153,7 @@ func (b *buffer) toString() string { return string(b.data[b.promptLen:b.size]) }
 
 // Refreshes the line.
 func (b *buffer) refresh() (err error) {
-	lastLine, _ := b.pos2xy(b.size)
-	posLine, posColumn := b.pos2xy(b.pos)
+	posLine, _ := b.pos2xy(b.pos)
 
 	// To the first line.
 	for ln := posLine; ln > 0; ln-- {
@@ -127,6 +162,15 @@ func (b *buffer) refresh() (err error) {
 		}
 	}
 
+	return b.redraw()
+}
+
+// Writes the whole line from the start of the actual line of the terminal,
+// and moves the cursor to its position into the buffer.
+func (b *buffer) redraw() (err error) {
+	lastLine, _ := b.pos2xy(b.size)
+	posLine, posColumn := b.pos2xy(b.pos)
+
 	// === Write the line
 	if _, err = output.Write(_CR); err != nil {
 		return outputError(err.Error())
@@ -144,7 +188,7 @@ func (b *buffer) refresh() (err error) {
 			return outputError(err.Error())
 		}
 	}
-	if _, err = fmt.Fprintf(output, "\r\033[%dC", posColumn); err != nil {
+	if _, err = output.Write(toColumn(posColumn)); err != nil {
 		return outputError(err.Error())
 	}
 
@@ -166,7 +210,7 @@ func (b *buffer) start() (err error) {
 		}
 	}
 
-	if _, err = fmt.Fprintf(output, "\r\033[%dC", b.promptLen); err != nil {
+	if _, err = output.Write(toColumn(b.promptLen)); err != nil {
 		return outputError(err.Error())
 	}
 
@@ -176,19 +220,19 @@ func (b *buffer) start() (err error) {
 
 // Moves the cursor at the end. Returns the number of lines that fill in the data.
 func (b *buffer) end() (lines int, err error) {
+	lastLine, lastColumn := b.pos2xy(b.size)
+
 	if b.pos == b.size {
-		return
+		return lastLine, nil
 	}
 
-	lastLine, lastColumn := b.pos2xy(b.size)
-
 	for ln, _ := b.pos2xy(b.pos); ln < lastLine; ln++ {
 		if _, err = output.Write(cursorDown); err != nil {
 			return 0, outputError(err.Error())
 		}
 	}
 
-	if _, err = fmt.Fprintf(output, "\r\033[%dC", lastColumn); err != nil {
+	if _, err = output.Write(toColumn(lastColumn)); err != nil {
 		return 0, outputError(err.Error())
 	}
 
@@ -213,7 +257,7 @@ func (b *buffer) backward() (err error) {
 		if _, err = output.Write(cursorUp); err != nil {
 			return outputError(err.Error())
 		}
-		if _, err = fmt.Fprintf(output, "\033[%dC", b.winColumns); err != nil {
+		if _, err = fmt.Fprint(output, tparm(parmForward, b.winColumns)); err != nil {
 			return outputError(err.Error())
 		}
 	}
@@ -352,13 +396,64 @@ func (b *buffer) deleteLine() error {
 	return nil
 }
 
+// === Undo
+// ===
+
+// Saves the actual state of the text to can be restored by 'undoLast'.
+func (b *buffer) saveUndo() {
+	text := make([]rune, b.size-b.promptLen)
+	copy(text, b.data[b.promptLen:b.size])
+
+	b.undo = append(b.undo, bufState{b.pos, text})
+}
+
+// Restores the text to the last state saved.
+func (b *buffer) undoLast() error {
+	if len(b.undo) == 0 {
+		return nil
+	}
+
+	last := b.undo[len(b.undo)-1]
+	b.undo = b.undo[:len(b.undo)-1]
+
+	return b.replace(last.text, last.pos)
+}
+
+// Replaces the text, setting the cursor at position 'pos'.
+// The characters that do not fit into the buffer capacity are discarded.
+func (b *buffer) replace(text []rune, pos int) error {
+	if free := cap(b.data) - b.promptLen; len(text) > free {
+		text = text[:free]
+	}
+	if pos > b.promptLen+len(text) {
+		pos = b.promptLen + len(text)
+	}
+
+	// The new text could fill less lines.
+	if err := b.deleteLine(); err != nil {
+		return err
+	}
+
+	b.grow(b.promptLen + len(text))
+	copy(b.data[b.promptLen:], text)
+	b.size = b.promptLen + len(text)
+	b.pos = pos
+
+	return b.redraw()
+}
+
 // === Utility
 // ===
 
-// Grows buffer to guarantee space for n more byte.
+// Grows buffer to guarantee space for n more byte, without exceeding its
+// capacity.
 func (b *buffer) grow(n int) {
-	for n > len(b.data) {
-		b.data = b.data[:len(b.data)+BufferLen]
+	for n > len(b.data) && len(b.data) < cap(b.data) {
+		size := len(b.data) + BufferLen
+		if size > cap(b.data) {
+			size = cap(b.data)
+		}
+		b.data = b.data[:size]
 	}
 }
 
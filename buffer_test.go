@@ -0,0 +1,44 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBufferReplace(t *testing.T) {
+	discardOutput(t)
+
+	prompt := []rune("> ")
+	b := &buffer{winColumns: 80, promptLen: len(prompt)}
+	b.data = make([]rune, BufferLen, BufferCap)
+	b.insertText(prompt)
+
+	// A line longer than the buffer, as one pasted.
+	long := []rune(strings.Repeat("x", BufferCap+100))
+	if err := b.replace(long, len(prompt)+len(long)); err != nil {
+		t.Fatal(err)
+	}
+	if b.size != BufferCap || b.pos != BufferCap {
+		t.Errorf("long line: size %d, position %d; want %d", b.size, b.pos, BufferCap)
+	}
+
+	b.saveUndo()
+	if err := b.replace([]rune("ls"), len(prompt)+2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.undoLast(); err != nil {
+		t.Fatal(err)
+	}
+	if b.toString() != string(long[:BufferCap-len(prompt)]) {
+		t.Errorf("undo: got %d characters", len([]rune(b.toString())))
+	}
+}
@@ -4,11 +4,17 @@ package linoise
 var (
 	_CR    = []byte{13}     // Carriage return -- \r
 	_CR_LF = []byte{13, 10} // CR+LF is used for a new line in raw mode -- \r\n
-	ctrlC  = []rune("^C")
-	ctrlD  = []rune("^D")
 )
 
-// ANSI terminal escape controls
+// Strings echoed when Ctrl-C and Ctrl-D are pressed. They can be set to an
+// empty string to echo nothing.
+var (
+	CtrlCString = "^C"
+	CtrlDString = "^D"
+)
+
+// Terminal escape controls. The values by default are ANSI, and they are set
+// from terminfo at initializing.
 var (
 	// === Cursor control
 	cursorUp       = []byte("\033[A") // Up
@@ -30,7 +36,28 @@ var (
 	delChar      = []byte("\033[P") // Delete character, from current position
 	delBackspace = []byte("\033[D\033[P")
 
+	// === Bracketed paste
+	pasteOn  = []byte("\033[?2004h") // Enable bracketed paste mode
+	pasteOff = []byte("\033[?2004l") // Disable bracketed paste mode
+	pasteEnd = []byte("\033[201~")   // Sent after of pasted text
+
+	// === Parameterized strings, instantiated by 'tparm'
+	parmForward = "\033[%p1%dC" // Move the cursor forward n columns
+
+	// === Graphic mode
+	setOff     = "\033[0m" // All attributes off
+	setBold    = "\033[1m" // Bold on
+	setReverse = []byte("\033[7m")
+
 	// === Misc.
 	//insertChar  = []byte("\033[@")   // Insert CHaracter
 	//setLineWrap = []byte("\033[?7h") // Enable Line Wrap
 )
+
+// Returns the control to move the cursor to the column 'col' of the actual line.
+func toColumn(col int) []byte {
+	if col == 0 {
+		return _CR
+	}
+	return []byte("\r" + tparm(parmForward, col))
+}
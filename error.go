@@ -14,10 +14,14 @@ import (
 )
 
 var (
-	ErrCtrlD = fmt.Errorf("Interrumpted (Ctrl-d)")
+	ErrCtrlD       = fmt.Errorf("Interrumpted (Ctrl-d)")
+	ErrInterrupted = fmt.Errorf("Interrupted (Ctrl-c)")
 
 	ErrEmptyHist  = fmt.Errorf("history: empty")
 	ErrNilElement = fmt.Errorf("history: no more elements")
+
+	ErrHistoryKey   = fmt.Errorf("history: wrong key, or the file is corrupted")
+	ErrHistoryNoKey = fmt.Errorf("history: the file is encrypted but there is no key")
 )
 
 // Represents a failure on input.
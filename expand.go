@@ -0,0 +1,254 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Expand the references to the history in the lines read, as csh and bash:
+//
+//	!!          the last line
+//	!n          the line n, where 1 is the oldest one
+//	!-n         the line n positions back
+//	!prefix     the last line starting with prefix
+//	!?substr?   the last line containing substr
+//	^old^new^   the last line, replacing old by new
+//
+// The line can be followed by a word designator, as ":n", ":x-y", ":^", ":$"
+// or ":*", where ':' can be omitted before of '^', '$' and '*'. Then, "!$" is
+// the last word of the last line.
+var HistoryExpand = false
+
+// Represents a failure at expanding the history.
+type expandError string
+
+func (e expandError) Error() string {
+	return string(e)
+}
+
+// Expands the references to the history of the line. Returns true if the line
+// was changed. The references into single quotes or preceded by '\' are not
+// expanded.
+func expandHistory(line string, hist History) (expanded string, changed bool, err error) {
+	if strings.HasPrefix(line, "^") {
+		return quickSubst(line, hist)
+	}
+	if strings.IndexByte(line, '!') == -1 {
+		return line, false, nil
+	}
+
+	var b strings.Builder
+	quoted := false // Into single quotes
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\'':
+			quoted = !quoted
+
+		case c == '\\' && i+1 < len(line) && line[i+1] == '!':
+			b.WriteString(line[i : i+2])
+			i++
+			continue
+
+		case c == '!' && !quoted:
+			n, text, err := expandEvent(line[i:], hist)
+			if err != nil {
+				return line, false, err
+			}
+			if n != 0 {
+				b.WriteString(text)
+				i += n - 1
+				changed = true
+				continue
+			}
+		}
+		b.WriteByte(line[i])
+	}
+
+	return b.String(), changed, nil
+}
+
+// Expands the reference at the start of 's', which starts with '!'. Returns the
+// number of bytes used by the reference, which is 0 if it is not one.
+func expandEvent(s string, hist History) (n int, text string, err error) {
+	if len(s) == 1 || strings.IndexByte(" \t\n=(", s[1]) != -1 {
+		return 0, "", nil
+	}
+
+	i := 1
+	pos := -1 // Position of the line in the history
+
+	switch c := s[1]; {
+	case c == '!':
+		pos, i = hist.Len()-1, 2
+
+	// Word designator of the last line.
+	case c == ':' || c == '^' || c == '$' || c == '*':
+		pos = hist.Len() - 1
+
+	case c == '-' || isDigit(c):
+		start := 1
+		if c == '-' {
+			start = 2
+		}
+		for i = start; i < len(s) && isDigit(s[i]); i++ {
+		}
+
+		num, err := strconv.Atoi(s[start:i])
+		if err != nil {
+			return 0, "", expandError(s[:i] + ": event not found")
+		}
+		if c == '-' {
+			pos = hist.Len() - num
+		} else {
+			pos = num - 1
+		}
+
+	case c == '?':
+		substr := s[2:]
+		if end := strings.IndexByte(substr, '?'); end != -1 {
+			substr = substr[:end]
+			i = 2 + end + 1
+		} else {
+			i = len(s)
+		}
+		pos = searchHistory(hist, func(line string) bool {
+			return strings.Contains(line, substr)
+		})
+
+	default:
+		for i < len(s) && strings.IndexByte(" \t\n:", s[i]) == -1 {
+			i++
+		}
+		prefix := s[1:i]
+		pos = searchHistory(hist, func(line string) bool {
+			return strings.HasPrefix(line, prefix)
+		})
+	}
+
+	if pos < 0 || pos >= hist.Len() {
+		return 0, "", expandError(s[:i] + ": event not found")
+	}
+	if text, err = hist.At(pos); err != nil {
+		return 0, "", err
+	}
+
+	// === Word designator
+	if i < len(s) && strings.IndexByte(":^$*", s[i]) != -1 {
+		j := i
+		if s[j] == ':' {
+			j++
+		}
+		start := j
+		for j < len(s) && strings.IndexByte("0123456789^$*-", s[j]) != -1 {
+			j++
+		}
+
+		if text, err = selectWords(text, s[start:j]); err != nil {
+			return 0, "", expandError(s[:j] + ": bad word specifier")
+		}
+		i = j
+	}
+
+	return i, text, nil
+}
+
+// Returns the words of the line selected by the designator, where 0 is the
+// first word.
+func selectWords(line, spec string) (string, error) {
+	if spec == "" {
+		return "", ErrNilElement
+	}
+	words := strings.Fields(line)
+	last := len(words) - 1
+
+	word := func(s string) (int, error) {
+		switch s {
+		case "^":
+			return 1, nil
+		case "$":
+			return last, nil
+		}
+		return strconv.Atoi(s)
+	}
+
+	var from, to int
+	var err, err2 error
+
+	switch dash := strings.IndexByte(spec, '-'); {
+	case spec == "*":
+		if last < 1 {
+			return "", nil
+		}
+		from, to = 1, last
+
+	case strings.HasSuffix(spec, "*"):
+		from, err = word(spec[:len(spec)-1])
+		to = last
+
+	case dash == 0:
+		to, err = word(spec[1:])
+
+	case dash == len(spec)-1:
+		from, err = word(spec[:dash])
+		to = last - 1
+
+	case dash != -1:
+		from, err = word(spec[:dash])
+		to, err2 = word(spec[dash+1:])
+
+	default:
+		from, err = word(spec)
+		to = from
+	}
+
+	if err != nil || err2 != nil || from < 0 || to > last || from > to {
+		return "", ErrNilElement
+	}
+	return strings.Join(words[from:to+1], " "), nil
+}
+
+// Expands the quick substitution "^old^new^", which replaces the first 'old'
+// of the last line by 'new'. The text after of the last '^' is appended.
+func quickSubst(line string, hist History) (string, bool, error) {
+	old, new, tail := line[1:], "", ""
+
+	if i := strings.IndexByte(old, '^'); i != -1 {
+		old, new = old[:i], old[i+1:]
+
+		if i = strings.IndexByte(new, '^'); i != -1 {
+			new, tail = new[:i], new[i+1:]
+		}
+	}
+
+	last, err := hist.At(hist.Len() - 1)
+	if err != nil {
+		return line, false, expandError(line + ": event not found")
+	}
+	if old == "" || !strings.Contains(last, old) {
+		return line, false, expandError(line + ": substitution failed")
+	}
+
+	return strings.Replace(last, old, new, 1) + tail, true, nil
+}
+
+// Returns the position of the last line that matches, or -1.
+func searchHistory(hist History, match func(line string) bool) int {
+	for i := hist.Len() - 1; i >= 0; i-- {
+		if line, err := hist.At(i); err == nil && match(line) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
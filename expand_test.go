@@ -0,0 +1,61 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	hist := NewMemHistory()
+	for _, line := range []string{
+		"ls -l /tmp",
+		"make install",
+		"cp a.txt b.txt dir",
+	} {
+		hist.Add(line)
+	}
+
+	for _, tt := range []struct {
+		in, out string
+		changed bool
+	}{
+		{"echo hi", "echo hi", false},
+		{"!!", "cp a.txt b.txt dir", true},
+		{"sudo !!", "sudo cp a.txt b.txt dir", true},
+		{"!1", "ls -l /tmp", true},
+		{"!-2", "make install", true},
+		{"!ma", "make install", true},
+		{"!?tmp?", "ls -l /tmp", true},
+		{"!?tmp", "ls -l /tmp", true},
+		{"vi !$", "vi dir", true},
+		{"vi !^", "vi a.txt", true},
+		{"echo !*", "echo a.txt b.txt dir", true},
+		{"echo !ls:2", "echo /tmp", true},
+		{"echo !!:1-2", "echo a.txt b.txt", true},
+		{"echo !!:2*", "echo b.txt dir", true},
+		{"echo !!:1-", "echo a.txt b.txt", true},
+		{"!ls:0 !$", "ls dir", true},
+		{"echo !", "echo !", false},
+		{"echo '!!'", "echo '!!'", false},
+		{`echo \!!`, `echo \!!`, false},
+		{"^b.txt^c.txt^", "cp a.txt c.txt dir", true},
+		{"^dir^other^ -v", "cp a.txt b.txt other -v", true},
+	} {
+		out, changed, err := expandHistory(tt.in, hist)
+		if err != nil || out != tt.out || changed != tt.changed {
+			t.Errorf("%q: got %q, %v, %v; want %q", tt.in, out, changed, err, tt.out)
+		}
+	}
+
+	for _, in := range []string{"!9", "!-4", "!none", "!?none?", "!!:9", "!!:", "^zz^y"} {
+		if _, _, err := expandHistory(in, hist); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
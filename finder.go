@@ -0,0 +1,254 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Values by default of the finder of the history.
+var (
+	FinderLines  = 10       // Lines shown below of the query
+	FinderPrompt = "find: " // Prompt of the query
+)
+
+// finder is a picker of lines of the history, matched by a fuzzy query. It is
+// shown below of the line being edited.
+type finder struct {
+	hist    History
+	lines   int // Lines of matches shown
+	columns int
+	prompt  string
+
+	query    []rune
+	matches  []fuzzyResult
+	selected int // Position in 'matches'
+	top      int // First match shown
+}
+
+func newFinder(hist History, query string, columns int) *finder {
+	f := &finder{
+		hist:    hist,
+		lines:   FinderLines,
+		columns: columns,
+		prompt:  FinderPrompt,
+		query:   []rune(query),
+	}
+	f.search()
+	return f
+}
+
+// Gets the matches of the query, without duplicated lines.
+func (f *finder) search() {
+	seen := make(map[string]bool)
+	f.matches = f.matches[:0]
+
+	for _, m := range fuzzySearch(f.hist, string(f.query)) {
+		if !seen[m.line] {
+			seen[m.line] = true
+			f.matches = append(f.matches, m)
+		}
+	}
+	f.selected, f.top = 0, 0
+}
+
+// Moves the selection 'n' matches, scrolling the matches shown.
+func (f *finder) move(n int) {
+	f.selected, f.top = scroll(f.selected+n, f.top, len(f.matches), f.lines)
+}
+
+// Returns the position selected into the 'count' items, and the first item
+// shown into 'lines' lines so the selected one is visible.
+func scroll(selected, top, count, lines int) (int, int) {
+	if selected >= count {
+		selected = count - 1
+	}
+	if selected < 0 {
+		selected = 0
+	}
+
+	if selected < top {
+		top = selected
+	} else if selected >= top+lines {
+		top = selected - lines + 1
+	}
+	return selected, top
+}
+
+// === Output
+// ===
+
+// Writes the query and the matches from the start of the line of the query,
+// where the cursor is left.
+func (f *finder) draw() error {
+	var b bytes.Buffer
+
+	b.WriteString("\r")
+	b.Write(delRight)
+	b.WriteString(f.prompt)
+	b.WriteString(string(f.query))
+
+	for i := 0; i < f.lines; i++ {
+		b.Write(_CR_LF)
+		b.Write(delRight)
+
+		if j := f.top + i; j < len(f.matches) {
+			f.drawMatch(&b, f.matches[j], j == f.selected)
+		}
+	}
+
+	for i := 0; i < f.lines; i++ {
+		b.Write(cursorUp)
+	}
+	b.Write(toColumn(utf8.RuneCountInString(f.prompt) + len(f.query)))
+
+	if _, err := output.Write(b.Bytes()); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
+
+// Writes a match, cut to the width of the terminal. The characters matched are
+// in bold, and the match selected is in reverse video.
+func (f *finder) drawMatch(b *bytes.Buffer, m fuzzyResult, selected bool) {
+	attr := setOff
+	if selected {
+		attr += string(setReverse)
+		b.Write(setReverse)
+	}
+
+	writeMatched(b, []rune(m.line), m.match, f.columns-1, attr)
+
+	if selected {
+		b.WriteString(setOff)
+	}
+}
+
+// Writes the text cut to 'width' characters, with the characters at the
+// positions matched in bold. 'attr' sets the attributes after of each one.
+func writeMatched(b *bytes.Buffer, text []rune, match []int, width int, attr string) {
+	if width < 0 {
+		width = 0
+	}
+	if len(text) > width {
+		text = text[:width]
+	}
+
+	k := 0 // Next character matched
+	for i, r := range text {
+		if unicode.IsControl(r) {
+			r = ' '
+		}
+
+		if k < len(match) && match[k] == i {
+			b.WriteString(setBold)
+			b.WriteRune(r)
+			b.WriteString(attr)
+			k++
+		} else {
+			b.WriteRune(r)
+		}
+	}
+}
+
+// Erases the query and the matches, from the line of the query. The cursor is
+// left at the previous line.
+func (f *finder) clear() error {
+	var b bytes.Buffer
+
+	b.WriteString("\r")
+	b.Write(delRight)
+	for i := 0; i < f.lines; i++ {
+		b.Write(cursorDown)
+		b.Write(delRight)
+	}
+	for i := 0; i <= f.lines; i++ {
+		b.Write(cursorUp)
+	}
+
+	if _, err := output.Write(b.Bytes()); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
+
+// === Get
+// ===
+
+// Shows the finder of the history below of the line, using the text of the
+// line as query. Returns the line chosen, or nil if it was cancelled.
+func (ln *Line) find() (line []rune, err error) {
+	if _, err = ln.buf.end(); err != nil {
+		return nil, err
+	}
+	if _, err = output.Write(_CR_LF); err != nil {
+		return nil, outputError(err.Error())
+	}
+
+	f := newFinder(ln.hist, ln.buf.toString(), ln.buf.winColumns)
+
+	for {
+		if err = f.draw(); err != nil {
+			return nil, err
+		}
+
+		key, err := readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case key.Code == KeyEnter:
+			if len(f.matches) != 0 {
+				line = []rune(f.matches[f.selected].line)
+			}
+			return line, f.clear()
+
+		case key.Code == KeyEscape, key == CtrlKey('g'), key == CtrlKey('c'):
+			return nil, f.clear()
+
+		case key.Code == KeyUp, key == CtrlKey('p'):
+			f.move(-1)
+		case key.Code == KeyDown, key == CtrlKey('n'), key == CtrlKey('r'):
+			f.move(1)
+		case key.Code == KeyPageUp:
+			f.move(-f.lines)
+		case key.Code == KeyPageDown:
+			f.move(f.lines)
+
+		case key.Code == KeyBackspace, key == CtrlKey('h'):
+			if len(f.query) != 0 {
+				f.query = f.query[:len(f.query)-1]
+				f.search()
+			}
+		case key == CtrlKey('u'):
+			f.query = f.query[:0]
+			f.search()
+
+		case key.Code == KeyPasteStart:
+			text, err := readPaste()
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range text {
+				if !unicode.IsControl(r) {
+					f.query = append(f.query, r)
+				}
+			}
+			f.search()
+
+		case key.isPrint():
+			f.query = append(f.query, key.Rune)
+			f.search()
+		}
+	}
+}
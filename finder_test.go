@@ -0,0 +1,69 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFinder(t *testing.T) {
+	hist := NewMemHistory()
+	for _, line := range []string{
+		"git checkout master", "make", "git commit", "ls", "git commit",
+	} {
+		hist.Add(line)
+	}
+
+	f := newFinder(hist, "gc", 80)
+	f.lines = 1
+
+	if len(f.matches) != 2 {
+		t.Fatalf("duplicated lines: got %d matches, want 2", len(f.matches))
+	}
+	if f.matches[0].line != "git commit" || f.matches[0].index != 4 {
+		t.Errorf("best match: got %q at %d", f.matches[0].line, f.matches[0].index)
+	}
+
+	f.move(1)
+	if f.selected != 1 || f.top != 1 {
+		t.Errorf("move down: selected %d, top %d", f.selected, f.top)
+	}
+	f.move(5)
+	if f.selected != 1 {
+		t.Errorf("move after of the last match: selected %d", f.selected)
+	}
+	f.move(-1)
+	if f.selected != 0 || f.top != 0 {
+		t.Errorf("move up: selected %d, top %d", f.selected, f.top)
+	}
+
+	var b bytes.Buffer
+	f.drawMatch(&b, f.matches[0], true)
+	want := string(setReverse) + setBold + "g" + setOff + string(setReverse) + "it " +
+		setBold + "c" + setOff + string(setReverse) + "ommit" + setOff
+	if b.String() != want {
+		t.Errorf("drawMatch: got %q, want %q", b.String(), want)
+	}
+
+	f.query = []rune("zzz")
+	f.search()
+	if len(f.matches) != 0 {
+		t.Errorf("no match: got %d matches", len(f.matches))
+	}
+
+	f = newFinder(hist, "", 6)
+	b.Reset()
+	f.drawMatch(&b, f.matches[0], false)
+	if s := b.String(); strings.Contains(s, "commit") {
+		t.Errorf("match not cut to the width: %q", s)
+	}
+}
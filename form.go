@@ -0,0 +1,410 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Answer to go back to the previous question in AskStruct.
+var QuestionBackString = "<"
+
+var errGoBack = errors.New("question: go back")
+
+// Asks a question for each exported field of the struct pointed by 'v', in
+// order, and the fields of the nested structs. The answer QuestionBackString
+// goes back to the previous question, whose answer is then the one by default.
+//
+// The questions are set with the tags of the fields:
+//
+//	prompt    the question; by default, the name of the field
+//	default   the answer by default
+//	validate  constraints separated by commas: "min=n" and "max=n" for the
+//	          value of numbers and the length of strings, and "regexp=expr"
+//	          for strings, which has to be the last one
+//	choices   options separated by commas, for strings
+//
+// A field with the tag `prompt:"-"` is not asked. The fields can be strings,
+// booleans and numbers.
+//
+// The key of the answers in Question.Answers and Question.Record is the path
+// of the field, as "DB.Driver", or the value of the tag "key".
+func (q *Question) AskStruct(v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("AskStruct: %T is not a pointer to a struct", v)
+	}
+
+	fields, err := formFields(ptr.Elem(), "", nil)
+	if err != nil {
+		return err
+	}
+
+	q.back = true
+	defer func() { q.back = false }()
+
+	defer func() { q.key = "" }()
+
+	answered := make([]bool, len(fields))
+
+	for i := 0; i < len(fields); {
+		q.key = fields[i].key
+		err = q.askField(fields[i], answered[i])
+		if err == errGoBack {
+			if i != 0 {
+				i--
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		answered[i] = true
+		i++
+	}
+	return nil
+}
+
+// === Fields
+// ===
+
+// Field of a struct to ask.
+type formField struct {
+	name   string
+	key    string // Key of the answer
+	value  reflect.Value
+	prompt string
+
+	def    string // Answer by default
+	hasDef bool
+
+	choices  []string
+	min, max *int64
+	re       *regexp.Regexp
+}
+
+// Returns the fields to ask of the struct, and of the nested ones. The keys of
+// the answers start with 'prefix'.
+func formFields(v reflect.Value, prefix string, fields []*formField) ([]*formField, error) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" { // Not exported
+			continue
+		}
+		prompt := sf.Tag.Get("prompt")
+		if prompt == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		// === Nested structs
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			var err error
+			if fields, err = formFields(fv, prefix+sf.Name+".", fields); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if prompt == "" {
+			prompt = sf.Name
+		}
+		f := &formField{name: sf.Name, key: prefix + sf.Name, value: fv, prompt: prompt}
+		if key := sf.Tag.Get("key"); key != "" {
+			f.key = key
+		}
+		f.def, f.hasDef = sf.Tag.Lookup("default")
+
+		if choices := sf.Tag.Get("choices"); choices != "" {
+			f.choices = strings.Split(choices, ",")
+		}
+		if err := f.parseValidate(sf.Tag.Get("validate")); err != nil {
+			return nil, err
+		}
+		if err := f.check(); err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Gets the constraints of the tag "validate".
+func (f *formField) parseValidate(tag string) error {
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regexp=") {
+			item, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i != -1 {
+			item, tag = tag[:i], tag[i+1:]
+		} else {
+			item, tag = tag, ""
+		}
+
+		eq := strings.IndexByte(item, '=')
+		if eq == -1 {
+			return f.errorf("wrong constraint %q", item)
+		}
+		key, value := item[:eq], item[eq+1:]
+
+		switch key {
+		case "min", "max":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return f.errorf("wrong constraint %q", item)
+			}
+			if key == "min" {
+				f.min = &n
+			} else {
+				f.max = &n
+			}
+
+		case "regexp":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return f.errorf("%v", err)
+			}
+			f.re = re
+
+		default:
+			return f.errorf("unknown constraint %q", key)
+		}
+	}
+	return nil
+}
+
+// Checks that the type is supported with the tags used, and the answer by
+// default.
+func (f *formField) check() (err error) {
+	kind := f.value.Kind()
+
+	if f.choices != nil && kind != reflect.String {
+		return f.errorf("choices are only for strings")
+	}
+	if f.re != nil && kind != reflect.String {
+		return f.errorf("regexp is only for strings")
+	}
+
+	switch kind {
+	case reflect.String:
+		if f.choices != nil && f.hasDef && f.choice(f.def) == -1 {
+			return f.errorf("the answer by default %q is not a choice", f.def)
+		}
+		return nil
+
+	case reflect.Bool:
+		if f.min != nil || f.max != nil {
+			return f.errorf("min and max are not for booleans")
+		}
+		if f.hasDef {
+			_, err = atob(f.def)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.hasDef {
+			_, err = strconv.ParseInt(f.def, 10, f.value.Type().Bits())
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f.hasDef {
+			_, err = strconv.ParseUint(f.def, 10, f.value.Type().Bits())
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if f.min != nil || f.max != nil {
+			return f.errorf("min and max are not for floats")
+		}
+		if f.hasDef {
+			_, err = strconv.ParseFloat(f.def, f.value.Type().Bits())
+		}
+
+	default:
+		return f.errorf("type %s is not supported", f.value.Type())
+	}
+
+	if err != nil {
+		return f.errorf("wrong answer by default %q", f.def)
+	}
+	return nil
+}
+
+// Returns the position of the choice, or -1.
+func (f *formField) choice(s string) int {
+	for i, c := range f.choices {
+		if c == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *formField) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("AskStruct: field %s: %s", f.name, fmt.Sprintf(format, a...))
+}
+
+// === Questions
+// ===
+
+// Asks the question of the field, setting its value. If it was answered, its
+// value is the answer by default.
+func (q *Question) askField(f *formField, answered bool) error {
+	def, hasDef := f.def, f.hasDef
+	if answered {
+		def, hasDef = fmt.Sprint(f.value.Interface()), true
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		var answer string
+		var err error
+
+		if f.choices != nil {
+			i := 0
+			if hasDef {
+				if i = f.choice(def); i == -1 {
+					i = 0
+				}
+			}
+			answer, err = q.ReadChoiceDefault(f.prompt, f.choices, uint(i))
+		} else {
+			// Any text is a string, although it represents a number.
+			valid := f.stringValidators()
+			if hasDef {
+				answer, err = AskDefault(q, f.prompt, parseText, nil, def, valid...)
+			} else {
+				answer, err = Ask(q, f.prompt, parseText, valid...)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		f.value.SetString(answer)
+
+	case reflect.Bool:
+		b := false
+		if hasDef {
+			b, _ = atob(def)
+		}
+		answer, err := q.ReadBool(f.prompt, b)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(answer)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var answer int64
+		var err error
+
+		if hasDef {
+			n, _ := strconv.ParseInt(def, 10, 64)
+			answer, err = AskDefault(q, f.prompt, ParseInt[int64], nil, n, f.intRange())
+		} else {
+			answer, err = Ask(q, f.prompt, ParseInt[int64], f.intRange())
+		}
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(answer)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var answer uint64
+		var err error
+
+		if hasDef {
+			n, _ := strconv.ParseUint(def, 10, 64)
+			answer, err = AskDefault(q, f.prompt, ParseUint[uint64], nil, n, f.uintRange())
+		} else {
+			answer, err = Ask(q, f.prompt, ParseUint[uint64], f.uintRange())
+		}
+		if err != nil {
+			return err
+		}
+		f.value.SetUint(answer)
+
+	case reflect.Float32, reflect.Float64:
+		var answer float64
+		var err error
+
+		if hasDef {
+			n, _ := strconv.ParseFloat(def, 64)
+			answer, err = q.ReadFloatDefault(f.prompt, n)
+		} else {
+			answer, err = q.ReadFloat(f.prompt)
+		}
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(answer)
+	}
+	return nil
+}
+
+// Returns the validators of the length and the regular expression.
+func (f *formField) stringValidators() []StringValidator {
+	valid := make([]StringValidator, 0)
+
+	if f.min != nil {
+		valid = append(valid, MinLen(int(*f.min)))
+	}
+	if f.max != nil {
+		valid = append(valid, MaxLen(int(*f.max)))
+	}
+	if f.re != nil {
+		valid = append(valid, MatchRegexp(f.re, ""))
+	}
+	return valid
+}
+
+// Returns the validator of the range of a signed number, which is limited by
+// the type of the field.
+func (f *formField) intRange() Validator[int64] {
+	max := int64(math.MaxInt64 >> uint(64-f.value.Type().Bits()))
+	min := -max - 1
+
+	if f.min != nil && *f.min > min {
+		min = *f.min
+	}
+	if f.max != nil && *f.max < max {
+		max = *f.max
+	}
+	return Range(min, max)
+}
+
+// Returns the validator of the range of an unsigned number, which is limited
+// by the type of the field.
+func (f *formField) uintRange() Validator[uint64] {
+	max := uint64(math.MaxUint64 >> uint(64-f.value.Type().Bits()))
+	min := uint64(0)
+
+	if f.min != nil && *f.min > 0 {
+		min = uint64(*f.min)
+	}
+	if f.max != nil && *f.max >= 0 && uint64(*f.max) < max {
+		max = uint64(*f.max)
+	}
+	return Range(min, max)
+}
@@ -0,0 +1,112 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type formDB struct {
+	Driver string `prompt:"Driver" choices:"postgres,mysql" default:"mysql"`
+	Name   string `validate:"min=2,regexp=^[a-z,]+$"`
+}
+
+type formConfig struct {
+	Host    string `prompt:"Host" default:"localhost"`
+	Port    uint16 `prompt:"Port" default:"8080" validate:"min=1"`
+	Debug   bool   `prompt:"Debug?"`
+	DB      formDB
+	Cache   *formDB
+	Skipped string `prompt:"-"`
+	private int
+}
+
+func TestFormFields(t *testing.T) {
+	var cfg formConfig
+
+	fields, err := formFields(reflect.ValueOf(&cfg).Elem(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.prompt
+	}
+	want := []string{"Host", "Port", "Debug?", "Driver", "Name", "Driver", "Name"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("fields: got %q, want %q", names, want)
+	}
+	if cfg.Cache == nil {
+		t.Error("nested pointer not allocated")
+	}
+
+	name := fields[4]
+	if name.min == nil || *name.min != 2 || name.re == nil || !name.re.MatchString("a,b") {
+		t.Errorf("validate of Name: min %v, regexp %v", name.min, name.re)
+	}
+	if err = validate("a", name.stringValidators()); err == nil {
+		t.Error("Name: expected error for the minimum length")
+	}
+
+	port := fields[1].uintRange()
+	if port(0) == nil || port(65535) != nil || port(65536) == nil {
+		t.Error("Port: wrong range")
+	}
+
+	// === Integers out of the range of int.
+	var big struct {
+		Size  uint64
+		Delta int8
+	}
+	if fields, err = formFields(reflect.ValueOf(&big).Elem(), "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewQuestion()
+	q.Answers = MapAnswers{"Size": "18446744073709551615", "Delta": "-128"}
+	for _, f := range fields {
+		if err = q.askField(f, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if big.Size != math.MaxUint64 || big.Delta != math.MinInt8 {
+		t.Errorf("integers: got %+v", big)
+	}
+
+	// === Wrong tags
+	for _, v := range []interface{}{
+		&struct {
+			A int `default:"x"`
+		}{},
+		&struct {
+			A int `choices:"a,b"`
+		}{},
+		&struct {
+			A string `choices:"a,b" default:"c"`
+		}{},
+		&struct {
+			A string `validate:"len=2"`
+		}{},
+		&struct {
+			A []int
+		}{},
+	} {
+		if _, err = formFields(reflect.ValueOf(v).Elem(), "", nil); err == nil {
+			t.Errorf("%T: expected error", v)
+		}
+	}
+
+	if err = NewQuestion().AskStruct(cfg); err == nil {
+		t.Error("AskStruct: expected error for a value")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Scores of the fuzzy matching.
+const (
+	fuzzyMatchScore   = 16 // Character matched
+	fuzzyConsecutive  = 16 // Character matched just after of the previous one
+	fuzzyWordStart    = 8  // Character matched at the start of a word
+	fuzzyFirstChar    = 8  // Character matched at the start of the text
+	fuzzyGapPenalty   = 1  // For each character skipped between matches
+	fuzzyLeadPenalty  = 1  // For each character skipped before of the first match
+	fuzzyMaxLeadCount = 8  // Maximum of characters penalized before of the first match
+)
+
+// Matches the characters of the pattern in order into the text, ignoring the
+// case, as "mkin" into "make install". Returns the score, which is greater when
+// the characters are consecutive or at the start of words, and the positions
+// of the characters matched, as runes. Returns false if it does not match.
+//
+// The characters are matched in the first position which gives consecutive
+// matches, so the score is not always the best one.
+func fuzzyMatch(pattern, text string) (score int, pos []int, ok bool) {
+	p, t := []rune(pattern), []rune(text)
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	pos = make([]int, 0, len(p))
+
+	pi := 0
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if unicode.ToLower(t[ti]) != unicode.ToLower(p[pi]) {
+			continue
+		}
+
+		// Prefer a later position that is at the start of a word, when this
+		// one does not follow the previous match, if the rest of the pattern
+		// is matched after of it.
+		if len(pos) == 0 || pos[len(pos)-1] != ti-1 {
+			if !isWordStart(t, ti) {
+				for j := ti + 1; j < len(t); j++ {
+					if unicode.ToLower(t[j]) == unicode.ToLower(p[pi]) && isWordStart(t, j) {
+						if isSubsequence(p[pi+1:], t[j+1:]) {
+							ti = j
+						}
+						break
+					}
+				}
+			}
+		}
+
+		score += fuzzyMatchScore
+		if isWordStart(t, ti) {
+			score += fuzzyWordStart
+		}
+		if ti == 0 {
+			score += fuzzyFirstChar
+		}
+
+		if len(pos) == 0 {
+			lead := ti
+			if lead > fuzzyMaxLeadCount {
+				lead = fuzzyMaxLeadCount
+			}
+			score -= lead * fuzzyLeadPenalty
+		} else if prev := pos[len(pos)-1]; prev == ti-1 {
+			score += fuzzyConsecutive
+		} else {
+			score -= (ti - prev - 1) * fuzzyGapPenalty
+		}
+
+		pos = append(pos, ti)
+		pi++
+	}
+
+	if pi != len(p) {
+		return 0, nil, false
+	}
+	return score, pos, true
+}
+
+// Reports whether the characters of 'p' are in 't' in the same order,
+// ignoring the case.
+func isSubsequence(p, t []rune) bool {
+	pi := 0
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if unicode.ToLower(t[ti]) == unicode.ToLower(p[pi]) {
+			pi++
+		}
+	}
+	return pi == len(p)
+}
+
+// Reports whether the character at position i starts a word.
+func isWordStart(t []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := t[i-1]
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev) ||
+		unicode.IsLower(prev) && unicode.IsUpper(t[i])
+}
+
+// === Search
+// ===
+
+// Line of the history matched by a fuzzy pattern.
+type fuzzyResult struct {
+	index int // Position in the history
+	line  string
+	score int
+	match []int // Positions of the characters matched, as runes
+}
+
+// Returns the lines of the history matched by the pattern, sorted from the best
+// match; the newer lines are first when the matches are equal.
+func fuzzySearch(hist History, pattern string) []fuzzyResult {
+	matches := make([]fuzzyResult, 0)
+
+	eachLine(hist, func(i int, line string) {
+		if score, pos, ok := fuzzyMatch(pattern, line); ok {
+			matches = append(matches, fuzzyResult{i, line, score, pos})
+		}
+	})
+	sort.Sort(byScore(matches))
+	return matches
+}
+
+// Calls f for each line of the history, from the oldest one.
+func eachLine(hist History, f func(i int, line string)) {
+	// Avoid to walk the list for each line.
+	if h, ok := hist.(interface {
+		Each(func(int, *HistoryEntry) bool)
+	}); ok {
+		h.Each(func(i int, e *HistoryEntry) bool {
+			f(i, e.Line)
+			return true
+		})
+		return
+	}
+
+	for i := 0; i < hist.Len(); i++ {
+		if line, err := hist.At(i); err == nil {
+			f(i, line)
+		}
+	}
+}
+
+// Sorts by score, and by position for equal scores, from the greatest.
+type byScore []fuzzyResult
+
+func (s byScore) Len() int      { return len(s) }
+func (s byScore) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byScore) Less(i, j int) bool {
+	if s[i].score != s[j].score {
+		return s[i].score > s[j].score
+	}
+	return s[i].index > s[j].index
+}
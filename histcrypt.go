@@ -0,0 +1,104 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Header of the encrypted history files. It is followed by the nonce and the
+// lines sealed with AES-GCM, as in a file without encryption.
+const historyEncrypted = "#!linoise aes-gcm\n"
+
+// Encrypts the text of a history file.
+func encryptHistory(key, text []byte) ([]byte, error) {
+	aead, err := newHistoryCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, len(historyEncrypted)+aead.NonceSize(),
+		len(historyEncrypted)+aead.NonceSize()+len(text)+aead.Overhead())
+	copy(data, historyEncrypted)
+
+	nonce := data[len(historyEncrypted):]
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	// The header is authenticated too.
+	return aead.Seal(data, nonce, text, []byte(historyEncrypted)), nil
+}
+
+// Decrypts a history file, with the header.
+func decryptHistory(key, data []byte) ([]byte, error) {
+	if key == nil {
+		return nil, ErrHistoryNoKey
+	}
+	aead, err := newHistoryCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(historyEncrypted):]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrHistoryKey
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	text, err := aead.Open(nil, nonce, sealed, []byte(historyEncrypted))
+	if err != nil {
+		return nil, ErrHistoryKey
+	}
+	return text, nil
+}
+
+func newHistoryCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Reports whether the file starts with the header of the encrypted files,
+// leaving it at the start.
+func isEncrypted(file *os.File) (bool, error) {
+	header := make([]byte, len(historyEncrypted))
+
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	if _, err = file.Seek(0, 0); err != nil {
+		return false, err
+	}
+	return n == len(header) && string(header) == historyEncrypted, nil
+}
+
+// Returns the text of the encrypted file.
+func (h *FileHistory) decryptFile(file *os.File) (io.Reader, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := decryptHistory(h.Key, data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(text), nil
+}
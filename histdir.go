@@ -0,0 +1,138 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Permission of the directories created for the histories.
+var HistoryDirPerm uint32 = 0700
+
+// HistoryDir keeps the histories of an application in a directory, with a file
+// for each namespace, as the sub-shells of a program. The histories are created
+// with the options of the directory, and each file has its own lock.
+type HistoryDir struct {
+	Dir string
+
+	// === Options of the histories
+	Cap            int // Capacity; if 0, it is used HistoryCap
+	FileCap        int
+	Format         HistoryFormat
+	Append         bool
+	Share          bool
+	Control        HistControl
+	IgnorePatterns []string
+	IgnoreFunc     func(line string) bool
+	Key            []byte
+
+	histories map[string]*FileHistory
+}
+
+// Creates the directory of histories for the application, which is at
+// $XDG_STATE_HOME/<app>. If that variable is not set, it is used
+// $XDG_DATA_HOME, and else "~/.local/state".
+func NewHistoryDir(app string) (*HistoryDir, error) {
+	if err := checkHistoryName(app); err != nil {
+		return nil, err
+	}
+
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		base = os.Getenv("XDG_DATA_HOME")
+	}
+	if base == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return nil, errors.New("history: no directory for the state (HOME is not set)")
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+
+	return NewHistoryDirPath(filepath.Join(base, app))
+}
+
+// Creates the directory of histories at the path given, with its parents.
+func NewHistoryDirPath(dir string) (*HistoryDir, error) {
+	if err := os.MkdirAll(dir, os.FileMode(HistoryDirPerm)); err != nil {
+		return nil, err
+	}
+
+	return &HistoryDir{
+		Dir:            dir,
+		FileCap:        HistoryFileCap,
+		Format:         HistoryFileFormat,
+		Control:        HistoryControl,
+		IgnorePatterns: HistoryIgnore,
+		histories:      make(map[string]*FileHistory),
+	}, nil
+}
+
+// Returns the history of the namespace, which is loaded from its file the first
+// time. The file is "<name>.history" into the directory.
+func (d *HistoryDir) Open(name string) (*FileHistory, error) {
+	if h, ok := d.histories[name]; ok {
+		return h, nil
+	}
+	if err := checkHistoryName(name); err != nil {
+		return nil, err
+	}
+
+	size := d.Cap
+	if size == 0 {
+		size = HistoryCap
+	}
+	if err := checkHistorySize(size); err != nil {
+		return nil, err
+	}
+
+	h, err := _baseHistory(filepath.Join(d.Dir, name+".history"), size)
+	if err != nil {
+		return nil, err
+	}
+	h.FileCap = d.FileCap
+	h.Format = d.Format
+	h.Append = d.Append
+	h.Share = d.Share
+	h.Control = d.Control
+	h.IgnorePatterns = d.IgnorePatterns
+	h.IgnoreFunc = d.IgnoreFunc
+	h.Key = d.Key
+
+	if err = h.Load(); err != nil {
+		return nil, err
+	}
+
+	d.histories[name] = h
+	return h, nil
+}
+
+// Saves the histories opened. Returns the first error found.
+func (d *HistoryDir) Save() (err error) {
+	for _, h := range d.histories {
+		if e := h.Save(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Checks that the name can be used as a file name.
+func checkHistoryName(name string) error {
+	if name == "" || name == "." || name == ".." ||
+		strings.ContainsAny(name, `/\`) || strings.IndexByte(name, 0) != -1 {
+		return fmt.Errorf("wrong history name: %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,573 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// === Type
+// ===
+
+// FileHistory is a history whose lines are saved to a text file.
+//
+// Several programs can use the same file at once; the access is serialized
+// through an advisory lock, and the lines written by other programs are merged
+// at loading and saving, so no history is lost.
+type FileHistory struct {
+	MemHistory
+	FileCap  int // Lines saved to the file, which can be greater than 'Cap'
+	filename string
+
+	// Writes each line to the file when it is added, instead of at saving.
+	Append bool
+
+	// Gets the lines added by other programs when it is moved to the previous
+	// line from the last one. They are written to the file by 'Append'.
+	Share bool
+
+	// Format used to read and write the file.
+	Format HistoryFormat
+
+	// Key to encrypt the file with AES-GCM, of 16, 24 or 32 bytes. A file
+	// without encryption is read, and it is encrypted at saving it.
+	Key []byte
+
+	older     []*HistoryEntry // Entries removed from the buffer that are still saved
+	pending   []*HistoryEntry // Entries added that are not in the file yet
+	info      os.FileInfo     // File read at last
+	offset    int64           // Bytes read from the file
+	escaped   bool            // The lines of the file are escaped
+	encrypted bool            // The file read at last is encrypted
+}
+
+// Base to create an history file.
+func _baseHistory(fname string, size int) (*FileHistory, error) {
+	// Check that the file can be used.
+	file, err := os.OpenFile(fname, os.O_CREATE|os.O_RDONLY, os.FileMode(HistoryPerm))
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	h := new(FileHistory)
+	h.MemHistory = *_baseMemHistory(size)
+	h.evict = h.keepOlder
+	h.FileCap = HistoryFileCap
+	h.Format = HistoryFileFormat
+	h.filename = fname
+
+	return h, nil
+}
+
+// Creates a new history using the maximum length by default.
+func NewHistory(filename string) (*FileHistory, error) {
+	return _baseHistory(filename, HistoryCap)
+}
+
+// Creates a new history whose buffer has the specified size, which must be
+// greater than zero.
+func NewHistorySize(filename string, size int) (*FileHistory, error) {
+	if err := checkHistorySize(size); err != nil {
+		return nil, err
+	}
+
+	return _baseHistory(filename, size)
+}
+
+// === Access to file
+// ===
+
+// Loads the history from the file. It is not an error if the file does not
+// exist.
+//
+// The lines that were already in the history and not saved are placed after of
+// the ones in the file, removing the older duplicates.
+func (h *FileHistory) Load() error {
+	lock, err := lockFile(h.filename, syscall.LOCK_SH)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	if err = h.readFile(); err != nil {
+		return err
+	}
+
+	h.mark = nil
+	return nil
+}
+
+// Saves the last lines to the text file, until the capacity of the file,
+// excep when:
+// + it starts with some space
+// + it is an empty line
+//
+// The lines are written to a temporary file which replaces the original one,
+// so the history is not corrupted if the program fails while saving. It can be
+// called several times.
+func (h *FileHistory) Save() error {
+	lock, err := lockFile(h.filename, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	return h.save()
+}
+
+// Saves the history. The file has to be locked.
+func (h *FileHistory) save() error {
+	// Get the lines saved by other programs.
+	if err := h.readFile(); err != nil {
+		return err
+	}
+
+	entries := make([]*HistoryEntry, 0, len(h.older)+h.li.Len())
+
+	for _, e := range h.older {
+		if e = saveEntry(e); e != nil {
+			entries = append(entries, e)
+		}
+	}
+	for e := h.li.Front(); e != nil; e = e.Next() {
+		if e := saveEntry(e.Value.(*HistoryEntry)); e != nil {
+			entries = append(entries, e)
+		}
+	}
+
+	if n := len(entries) - h.fileCap(); n > 0 {
+		entries = entries[n:]
+	}
+
+	// The lines are only escaped when it is necessary.
+	escaped := false
+	for _, e := range entries {
+		if strings.IndexByte(e.Line, '\n') != -1 {
+			escaped = true
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if escaped {
+		buf.WriteString(historyEscaped + "\n")
+	}
+	for _, e := range entries {
+		buf.WriteString(formatEntry(e, h.Format, escaped))
+	}
+
+	data := buf.Bytes()
+	if h.Key != nil {
+		var err error
+		if data, err = encryptHistory(h.Key, data); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFile(h.filename, data, os.FileMode(HistoryPerm)); err != nil {
+		return err
+	}
+
+	h.escaped = escaped
+	h.encrypted = h.Key != nil
+	h.pending = h.pending[:0]
+	return h.setRead(int64(len(data)))
+}
+
+// Adds a new line to the buffer. When it is full, the oldest line is removed.
+// If it is set 'Append', the line is written to the file.
+// The line is not added if it is ignored by the policies of the history.
+func (h *FileHistory) Add(line string) {
+	h.add(h.newEntry(line))
+}
+
+// Adds an entry with information given by the application. If its time is
+// not set, it is used the actual one.
+// If it is set 'Append', the entry is written to the file.
+// The entry is not added if it is ignored by the policies of the history.
+func (h *FileHistory) AddEntry(e HistoryEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.add(&e)
+}
+
+// The lines removed by EraseDups are kept in the file until it is saved,
+// when it is set 'Append'.
+func (h *FileHistory) add(e *HistoryEntry) {
+	if h.ignore(e.Line) {
+		return
+	}
+	if h.Control&EraseDups != 0 {
+		h.older = eraseEntries(h.older, e.Line)
+	}
+
+	if !h.Append {
+		h.push(e)
+		h.mark = nil
+
+		if len(h.pending) == h.fileCap() {
+			h.pending = append(h.pending[:0], h.pending[1:]...)
+		}
+		h.pending = append(h.pending, e)
+		return
+	}
+
+	if err := h.appendEntry(e); err != nil {
+		log.Println("history.Add:", err)
+	}
+}
+
+// Returns the previous line. If it is set 'Share' and the cursor is after of
+// the last line, then it is got the lines added by other programs.
+func (h *FileHistory) Prev() (line []rune, err error) {
+	if h.Share && h.mark == nil {
+		if err = h.share(); err != nil {
+			return nil, err
+		}
+	}
+	return h.MemHistory.Prev()
+}
+
+// Sets the cursor after of the last line. If it is set 'Share', then it is
+// got the lines added by other programs.
+func (h *FileHistory) Reset() {
+	if h.Share {
+		if err := h.share(); err != nil {
+			log.Println("history.Reset:", err)
+		}
+	}
+	h.MemHistory.Reset()
+}
+
+// Gets the lines added to the file by other programs.
+func (h *FileHistory) share() error {
+	lock, err := lockFile(h.filename, syscall.LOCK_SH)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	return h.readFile()
+}
+
+// Writes an entry at the end of the file, after of getting the lines added by
+// other programs, so the order is kept. Since the entry is written when it is
+// added, its exit status and duration are only saved by 'Save'.
+func (h *FileHistory) appendEntry(e *HistoryEntry) error {
+	lock, err := lockFile(h.filename, syscall.LOCK_EX)
+	if err != nil {
+		h.push(e)
+		h.mark = nil
+		return err
+	}
+	defer unlockFile(lock)
+
+	err = h.readFile()
+	h.push(e)
+	h.mark = nil
+	if err != nil {
+		return err
+	}
+
+	if e = saveEntry(e); e == nil {
+		return nil
+	}
+
+	// The encrypted files are written fully.
+	if h.Key != nil {
+		return h.save()
+	}
+
+	// A line with newlines needs that the file has escaped lines; else, the
+	// file is rewritten.
+	if !h.escaped && strings.IndexByte(e.Line, '\n') != -1 {
+		if h.offset != 0 {
+			return h.save()
+		}
+
+		// Empty file, so the mark is written first.
+		if err = h.appendText(historyEscaped + "\n"); err != nil {
+			return err
+		}
+		h.escaped = true
+	}
+
+	return h.appendText(formatEntry(e, h.Format, h.escaped))
+}
+
+// Writes text at the end of the file. The file has to be locked.
+func (h *FileHistory) appendText(text string) error {
+	file, err := os.OpenFile(h.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		os.FileMode(HistoryPerm))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(text); err != nil {
+		return err
+	}
+	return h.setRead(h.offset + int64(len(text)))
+}
+
+// Reads the lines written to the file since the last access. If the file was
+// replaced, it is read fully and merged with the lines not saved yet.
+// The file has to be locked.
+func (h *FileHistory) readFile() error {
+	file, err := os.Open(h.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	merge := h.info == nil || !os.SameFile(h.info, info) || info.Size() < h.offset
+
+	// An encrypted file is always replaced, so it has not changed.
+	if !merge && h.encrypted {
+		return nil
+	}
+
+	var in io.Reader = file
+	if merge {
+		encrypted, err := isEncrypted(file)
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			if in, err = h.decryptFile(file); err != nil {
+				return err
+			}
+		}
+		h.encrypted = encrypted
+	} else {
+		if _, err = file.Seek(h.offset, 0); err != nil {
+			return err
+		}
+	}
+
+	lines, n, err := readLines(in)
+	if err != nil {
+		return err
+	}
+	if h.encrypted {
+		n = info.Size()
+	}
+
+	if merge {
+		h.escaped = len(lines) != 0 && lines[0] == historyEscaped
+		if h.escaped {
+			lines = lines[1:]
+		}
+	}
+	entries := parseEntries(lines, h.Format, h.escaped)
+
+	if merge {
+		h.merge(entries)
+		h.offset = n
+	} else {
+		for _, e := range entries {
+			h.push(e)
+		}
+		h.offset += n
+	}
+
+	h.info = info
+	return nil
+}
+
+// Replaces the entries in memory by the ones in the file, adding the entries
+// that are not saved yet. When there were lines in memory, the older duplicates
+// are removed.
+func (h *FileHistory) merge(entries []*HistoryEntry) {
+	dedup := h.li.Len() != 0
+
+	entries = append(entries, h.pending...)
+	if dedup {
+		entries = dedupEntries(entries)
+	}
+
+	h.li.Init()
+	h.older = h.older[:0]
+
+	for _, e := range entries {
+		h.push(e)
+	}
+	h.mark = nil
+}
+
+// Sets the bytes read from the file, which has been written by this program.
+func (h *FileHistory) setRead(offset int64) error {
+	info, err := os.Stat(h.filename)
+	if err != nil {
+		return err
+	}
+
+	h.info = info
+	h.offset = offset
+	return nil
+}
+
+// Reads lines from r, returning the number of bytes read. A last line without
+// newline is not got since it could be being written.
+func readLines(r io.Reader) (lines []string, n int64, err error) {
+	in := bufio.NewReader(r)
+
+	for {
+		line, err := in.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		n += int64(len(line))
+		lines = append(lines, strings.TrimRight(line, "\n"))
+	}
+	return lines, n, nil
+}
+
+// Removes the entries with the line.
+func eraseEntries(entries []*HistoryEntry, line string) []*HistoryEntry {
+	out := entries[:0]
+	for _, e := range entries {
+		if e.Line != line {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Removes the entries with duplicated lines, keeping the last one.
+func dedupEntries(entries []*HistoryEntry) []*HistoryEntry {
+	seen := make(map[string]bool, len(entries))
+	out := make([]*HistoryEntry, len(entries))
+	i := len(out)
+
+	for j := len(entries) - 1; j >= 0; j-- {
+		if line := entries[j].Line; !seen[line] {
+			seen[line] = true
+			i--
+			out[i] = entries[j]
+		}
+	}
+	return out[i:]
+}
+
+// Opens the lock file of the history, which is used instead of the history
+// file since this one is replaced at saving, and locks it.
+// 'how' is LOCK_SH or LOCK_EX.
+func lockFile(fname string, how int) (*os.File, error) {
+	file, err := os.OpenFile(fname+".lock", os.O_CREATE|os.O_RDONLY,
+		os.FileMode(HistoryPerm))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = syscall.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Unlocks and closes the lock file.
+func unlockFile(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	file.Close()
+}
+
+// Writes data to a temporary file in the same directory, which is synced and
+// renamed to the file name. If the name is a symbolic link, it is replaced
+// the file which it points to.
+func writeFile(fname string, data []byte, perm os.FileMode) (err error) {
+	if real, err := filepath.EvalSymlinks(fname); err == nil {
+		fname = real
+	}
+	dir, base := filepath.Split(fname)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), fname); err != nil {
+		return err
+	}
+
+	// Sync the directory so the new entry persists.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// ===
+// Keeps an entry removed from the buffer while it fits into the file.
+func (h *FileHistory) keepOlder(e *HistoryEntry) {
+	h.older = append(h.older, e)
+
+	if n := len(h.older) - (h.fileCap() - h.capacity()); n > 0 {
+		if n >= len(h.older) {
+			h.older = h.older[:0]
+		} else {
+			h.older = append(h.older[:0], h.older[n:]...)
+		}
+	}
+}
+
+// Returns the number of lines that can be saved to the file.
+func (h *FileHistory) fileCap() int {
+	if h.FileCap <= 0 {
+		return h.capacity()
+	}
+	return h.FileCap
+}
@@ -0,0 +1,270 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format of the history file.
+type HistoryFormat int
+
+const (
+	// Only the lines.
+	HistoryPlain HistoryFormat = iota
+
+	// The extended history of zsh, ": <time>:<duration>;<line>", with the
+	// time in seconds since the epoch.
+	HistoryZsh
+
+	// The format of bash when it is set HISTTIMEFORMAT, where the line is
+	// preceded by a comment "#<time>". The comment could have also the
+	// working directory, exit status and session, as:
+	//   #<time> duration=<seconds> status=<n> dir="<dir>" session="<id>"
+	HistoryBash
+)
+
+// Format by default of the history files.
+var HistoryFileFormat = HistoryPlain
+
+// First line of the files whose lines are escaped, which is only written when
+// some line has newlines. The files without it are read as they are.
+const historyEscaped = "#!linoise escaped"
+
+// === Writing
+// ===
+
+// Returns the entry as it is written to the file, with the newline.
+// If 'escaped' is true, the line is escaped.
+func formatEntry(e *HistoryEntry, f HistoryFormat, escaped bool) string {
+	line := e.Line
+	if escaped {
+		line = escapeLine(line)
+	}
+
+	switch f {
+	case HistoryZsh:
+		return ": " + strconv.FormatInt(unixTime(e.Time), 10) + ":" +
+			strconv.FormatInt(int64(e.Duration/time.Second), 10) + ";" + line + "\n"
+
+	case HistoryBash:
+		comment := "#" + strconv.FormatInt(unixTime(e.Time), 10)
+
+		if e.Duration != 0 {
+			comment += " duration=" + strconv.FormatInt(int64(e.Duration/time.Second), 10)
+		}
+		if e.Status != 0 {
+			comment += " status=" + strconv.Itoa(e.Status)
+		}
+		if e.Dir != "" {
+			comment += " dir=" + strconv.Quote(e.Dir)
+		}
+		if e.Session != "" {
+			comment += " session=" + strconv.Quote(e.Session)
+		}
+		return comment + "\n" + line + "\n"
+	}
+
+	return line + "\n"
+}
+
+// Escapes the backslashes and newlines, as "\\" and "\n".
+func escapeLine(line string) string {
+	if strings.IndexAny(line, "\\\n") == -1 {
+		return line
+	}
+
+	var b strings.Builder
+	for _, r := range line {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Undoes the escaping of 'escapeLine'. Other escapes are kept as they are.
+func unescapeLine(line string) string {
+	if strings.IndexByte(line, '\\') == -1 {
+		return line
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			switch line[i+1] {
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(line[i])
+	}
+	return b.String()
+}
+
+// Returns the time in seconds since the epoch, or 0 if it is not set.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// === Reading
+// ===
+
+// Returns the entries of the lines read from a file in the format 'f'. In the
+// plain format the lines are got as they are; else, the lines of both formats
+// zsh and bash are recognized, and the line after of a comment of bash is got
+// as it is. If 'escaped' is true, the escaping of the lines is undone.
+func parseEntries(lines []string, f HistoryFormat, escaped bool) []*HistoryEntry {
+	entries := make([]*HistoryEntry, 0, len(lines))
+	var comment *HistoryEntry // Got from a comment of bash
+
+	for _, line := range lines {
+		var e *HistoryEntry
+
+		switch {
+		case f == HistoryPlain:
+			e = &HistoryEntry{Line: line}
+		case comment != nil:
+			e = comment
+			e.Line = line
+		default:
+			if comment = parseComment(line); comment != nil {
+				continue
+			}
+			if e = parseZsh(line); e == nil {
+				e = &HistoryEntry{Line: line}
+			}
+		}
+
+		if escaped {
+			e.Line = unescapeLine(e.Line)
+		}
+		entries = append(entries, e)
+		comment = nil
+	}
+	return entries
+}
+
+// Parses a line in the extended format of zsh. Returns nil if it is not in
+// that format.
+func parseZsh(line string) *HistoryEntry {
+	if !strings.HasPrefix(line, ": ") {
+		return nil
+	}
+
+	semicolon := strings.IndexByte(line, ';')
+	if semicolon == -1 {
+		return nil
+	}
+	fields := strings.SplitN(line[2:semicolon], ":", 2)
+	if len(fields) != 2 {
+		return nil
+	}
+
+	sec, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil
+	}
+	dur, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &HistoryEntry{
+		Line:     line[semicolon+1:],
+		Time:     fromUnix(sec),
+		Duration: time.Duration(dur) * time.Second,
+	}
+}
+
+// Parses a comment of bash with the time of the next line, and the optional
+// fields. Returns nil if the line is not such comment.
+func parseComment(line string) *HistoryEntry {
+	if len(line) < 2 || line[0] != '#' || line[1] < '0' || line[1] > '9' {
+		return nil
+	}
+
+	end := 1
+	for end < len(line) && line[end] >= '0' && line[end] <= '9' {
+		end++
+	}
+	if end != len(line) && line[end] != ' ' {
+		return nil
+	}
+
+	sec, err := strconv.ParseInt(line[1:end], 10, 64)
+	if err != nil {
+		return nil
+	}
+	e := &HistoryEntry{Time: fromUnix(sec)}
+
+	// === Fields "key=value", where the value could be quoted.
+	for rest := strings.TrimLeft(line[end:], " "); rest != ""; rest = strings.TrimLeft(rest, " ") {
+		eq := strings.IndexByte(rest, '=')
+		if eq == -1 {
+			break
+		}
+		key, value := rest[:eq], rest[eq+1:]
+
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				break
+			}
+			rest = value[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			if sp := strings.IndexByte(value, ' '); sp != -1 {
+				value, rest = value[:sp], value[sp:]
+			} else {
+				rest = ""
+			}
+		}
+
+		switch key {
+		case "duration":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.Duration = time.Duration(n) * time.Second
+			}
+		case "status":
+			e.Status, _ = strconv.Atoi(value)
+		case "dir":
+			e.Dir = value
+		case "session":
+			e.Session = value
+		}
+	}
+
+	return e
+}
+
+// Returns the time for the seconds since the epoch, or the zero time for 0.
+func fromUnix(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -10,159 +10,474 @@
 package linoise
 
 import (
-	"bufio"
 	"container/list"
 	"fmt"
-	"io"
-	"log"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Values by default
 var (
-	HistoryCap         = 500  // Capacity
-	HistoryPerm uint32 = 0600 // History file permission
+	HistoryCap            = 500  // Capacity
+	HistoryFileCap        = 0    // Capacity of the file; if 0, it is used the capacity
+	HistoryPerm    uint32 = 0600 // History file permission
+
+	HistoryControl HistControl // Lines that are not added
+	HistoryIgnore  []string    // Patterns of lines that are not added
+)
+
+// Policies to choose the lines added to the history, as HISTCONTROL of bash.
+type HistControl uint8
+
+const (
+	IgnoreSpace HistControl = 1 << iota // Lines which start with a space
+	IgnoreDups                          // Lines equal to the previous one
+	EraseDups                           // Previous lines equal to the new one are removed
+
+	IgnoreBoth = IgnoreSpace | IgnoreDups
 )
 
+// === Interface
+// ===
+
+// History is the source of lines used by a Line to move between the previous
+// ones. It has a cursor which points to the actual line.
+type History interface {
+	// Adds a new line, setting the cursor after of it.
+	Add(line string)
+
+	// Moves the cursor to the previous line and returns it.
+	Prev() (line []rune, err error)
+
+	// Moves the cursor to the next line and returns it.
+	Next() (line []rune, err error)
+
+	// Returns the number of lines.
+	Len() int
+
+	// Returns the line at position i, where 0 is the oldest one.
+	At(i int) (line string, err error)
+
+	// Searches backward from the cursor the first line which contains the
+	// substring, moving the cursor to it. Returns its position.
+	Search(substr string) (i int, err error)
+
+	// Sets the cursor after of the last line, so the previous line is the
+	// last one.
+	Reset()
+
+	// Saves the lines to the storage.
+	Save() error
+
+	// Loads the lines from the storage.
+	Load() error
+}
+
 // === Type
 // ===
 
-type history struct {
-	Cap      int
-	filename string
-	file     *os.File
-	mark     *list.Element // Pointer to the last element added.
-	li       *list.List
+// HistoryEntry is a line of the history, with information about its execution
+// that can be given by the application.
+type HistoryEntry struct {
+	Line     string
+	Time     time.Time     // When it was added
+	Duration time.Duration // Time spent running the line
+	Dir      string        // Working directory
+	Status   int           // Exit status; 0 if it is unknown
+	Session  string        // Identifier of the session
 }
 
-// Base to create an history file.
-func _baseHistory(fname string, size int) (*history, error) {
-	file, err := os.OpenFile(fname, os.O_CREATE|os.O_RDWR, os.ModePerm)
-	if err != nil {
-		return nil, err
-	}
+// MemHistory is a history kept in memory, which is lost at exiting.
+// It is the base for other implementations.
+type MemHistory struct {
+	Cap       int    // Lines kept; if it is not greater than 0, it is used HistoryCap
+	Session   string // Identifier of the session set in the lines added
+	RecordDir bool   // Record the working directory in the lines added
+
+	// === Lines that are not added
+	Control HistControl
 
-	h := new(history)
-	h.Cap = size
-	h.filename = fname
-	h.file = file
-	h.li = list.New()
+	// Patterns which have to match the whole line, as HISTIGNORE of bash.
+	// They can have '*', '?' and '[...]'; and "&" matches the previous line.
+	IgnorePatterns []string
 
-	return h, nil
+	// If it is set and returns true, the line is not added. Useful to keep out
+	// lines with passwords.
+	IgnoreFunc func(line string) bool
+
+	mark *list.Element // Element of the cursor; nil is after of the last one.
+	li   *list.List    // Entries of type *HistoryEntry
+
+	evict func(e *HistoryEntry) // Called for each entry removed, if it is set.
 }
 
-// Creates a new history using the maximum length by default.
-func NewHistory(filename string) (*history, error) {
-	return _baseHistory(filename, HistoryCap)
+// Base to create an history.
+func _baseMemHistory(size int) *MemHistory {
+	return &MemHistory{
+		Cap:            size,
+		Control:        HistoryControl,
+		IgnorePatterns: HistoryIgnore,
+		li:             list.New(),
+	}
 }
 
-// Creates a new history whose buffer has the specified size, which must be
-// greater than zero.
-func NewHistorySize(filename string, size int) (*history, error) {
+// Creates a new history in memory using the maximum length by default.
+func NewMemHistory() *MemHistory {
+	return _baseMemHistory(HistoryCap)
+}
+
+// Creates a new history in memory whose buffer has the specified size, which
+// must be greater than zero.
+func NewMemHistorySize(size int) (*MemHistory, error) {
+	if err := checkHistorySize(size); err != nil {
+		return nil, err
+	}
+	return _baseMemHistory(size), nil
+}
+
+func checkHistorySize(size int) error {
 	if size <= 0 {
-		return nil, fmt.Errorf("wrong history size: " + strconv.Itoa(size))
+		return fmt.Errorf("wrong history size: " + strconv.Itoa(size))
 	}
+	return nil
+}
 
-	return _baseHistory(filename, size)
+// Tests if it has an history. A nil pointer, as the one returned by
+// NewHistory on error, is not an history.
+func hasHistory(h History) bool {
+	if h == nil {
+		return false
+	}
+	if v := reflect.ValueOf(h); v.Kind() == reflect.Ptr && v.IsNil() {
+		return false
+	}
+	return true
 }
 
-// === Access to file
+// === Storage
 // ===
 
-// Loads the history from the file.
-func (h *history) Load() {
-	in := bufio.NewReader(h.file)
+// Does nothing since the lines are only in memory.
+func (h *MemHistory) Save() error { return nil }
 
-	for {
-		line, err := in.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
+// Does nothing since the lines are only in memory.
+func (h *MemHistory) Load() error { return nil }
 
-		h.li.PushBack(strings.TrimRight(line, "\n"))
-	}
+// ===
 
-	h.mark = h.li.Back() // Point to an element.
+// Adds a new line to the buffer. When it is full, the oldest line is removed.
+// The line is not added if it is ignored by the policies of the history.
+func (h *MemHistory) Add(line string) {
+	if h.ignore(line) {
+		return
+	}
+	h.push(h.newEntry(line))
+	h.Reset()
 }
 
-// Saves all lines to the text file, excep when:
-// + it starts with some space
-// + it is an empty line
-func (h *history) Save() (err error) {
-	if _, err = h.file.Seek(0, 0); err != nil {
+// Adds an entry with information given by the application. If its time is
+// not set, it is used the actual one.
+// The entry is not added if it is ignored by the policies of the history.
+func (h *MemHistory) AddEntry(e HistoryEntry) {
+	if h.ignore(e.Line) {
 		return
 	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.push(&e)
+	h.Reset()
+}
 
-	out := bufio.NewWriter(h.file)
-	element := h.li.Front() // Get the first element.
+// Sets the exit status of the last line, and the time spent since it was added.
+func (h *MemHistory) Finish(status int) {
+	if last := h.li.Back(); last != nil {
+		e := last.Value.(*HistoryEntry)
+		e.Status = status
+		e.Duration = time.Since(e.Time)
+	}
+}
 
-	for i := 0; i < h.li.Len(); i++ {
-		line := element.Value.(string)
+// Returns a new entry for the line.
+func (h *MemHistory) newEntry(line string) *HistoryEntry {
+	e := &HistoryEntry{Line: line, Time: time.Now(), Session: h.Session}
 
-		if strings.HasPrefix(line, " ") {
-			goto _next
+	if h.RecordDir {
+		e.Dir, _ = os.Getwd()
+	}
+	return e
+}
+
+// Adds an entry at the end, removing the first one if the buffer is full.
+func (h *MemHistory) push(e *HistoryEntry) *list.Element {
+	for h.li.Len() != 0 && h.li.Len() >= h.capacity() {
+		old := h.li.Remove(h.li.Front()).(*HistoryEntry)
+
+		if h.evict != nil {
+			h.evict(old)
 		}
-		if line = strings.TrimSpace(line); line == "" {
-			goto _next
+	}
+
+	return h.li.PushBack(e)
+}
+
+// Returns the number of lines kept, which is HistoryCap if 'Cap' is not
+// greater than 0.
+func (h *MemHistory) capacity() int {
+	if h.Cap <= 0 {
+		return HistoryCap
+	}
+	return h.Cap
+}
+
+// === Policies
+// ===
+
+// Checks if the line has not to be added. When it is added and it is set
+// EraseDups, the previous lines equal to it are removed.
+func (h *MemHistory) ignore(line string) bool {
+	var last string
+	if e := h.li.Back(); e != nil {
+		last = e.Value.(*HistoryEntry).Line
+	}
+
+	if h.Control&IgnoreSpace != 0 && strings.HasPrefix(line, " ") {
+		return true
+	}
+	if h.Control&IgnoreDups != 0 && h.li.Len() != 0 && line == last {
+		return true
+	}
+
+	for _, pattern := range h.IgnorePatterns {
+		if pattern == "&" {
+			if h.li.Len() != 0 && line == last {
+				return true
+			}
+		} else if matchGlob(pattern, line) {
+			return true
 		}
-		if _, err = out.WriteString(line + "\n"); err != nil {
-			log.Println("history.Save:", err)
-			break
+	}
+
+	if h.IgnoreFunc != nil && h.IgnoreFunc(line) {
+		return true
+	}
+
+	if h.Control&EraseDups != 0 {
+		for e := h.li.Front(); e != nil; {
+			next := e.Next()
+			if e.Value.(*HistoryEntry).Line == line {
+				if e == h.mark {
+					h.mark = nil
+				}
+				h.li.Remove(e)
+			}
+			e = next
+		}
+	}
+	return false
+}
+
+// Reports whether the string matches the whole shell pattern, where '*'
+// matches any string, '?' any character, "[...]" a character in the set, and
+// '\' escapes the next character.
+func matchGlob(pattern, s string) bool {
+	p, r := []rune(pattern), []rune(s)
+	pi, ri := 0, 0
+	star, starR := -1, 0 // Last '*' found, and the position to match it
+
+	for ri < len(r) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				star, starR = pi, ri
+				pi++
+				continue
+			case '?':
+				pi++
+				ri++
+				continue
+			case '[':
+				if match, n := matchClass(p[pi:], r[ri]); n != 0 {
+					if match {
+						pi += n
+						ri++
+						continue
+					}
+					break
+				}
+				if r[ri] == '[' { // Without closing, it is a character.
+					pi++
+					ri++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == r[ri] {
+					pi += 2
+					ri++
+					continue
+				}
+			default:
+				if p[pi] == r[ri] {
+					pi++
+					ri++
+					continue
+				}
+			}
+		}
+
+		// Try that the last '*' matches one more character.
+		if star == -1 {
+			return false
+		}
+		starR++
+		pi, ri = star+1, starR
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// Matches the character against the set at the start of the pattern, as
+// "[a-z]" or "[!0-9]". Returns the length of the set, or 0 if it is not closed.
+func matchClass(p []rune, c rune) (match bool, n int) {
+	i := 1
+	negate := i < len(p) && (p[i] == '!' || p[i] == '^')
+	if negate {
+		i++
+	}
+
+	for start := i; i < len(p); i++ {
+		if p[i] == ']' && i != start {
+			return match != negate, i + 1
 		}
 
-	_next:
-		if element = element.Next(); element == nil {
-			continue
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			if p[i] <= c && c <= p[i+2] {
+				match = true
+			}
+			i += 2
+		} else if p[i] == c {
+			match = true
 		}
 	}
+	return false, 0
+}
+
+// Returns the entry to save, with the line trimmed, or nil if it has not to be
+// saved.
+func saveEntry(e *HistoryEntry) *HistoryEntry {
+	if strings.HasPrefix(e.Line, " ") {
+		return nil
+	}
 
-	if err = out.Flush(); err != nil {
-		log.Println("history.Save:", err)
+	line := strings.TrimSpace(e.Line)
+	if line == "" {
+		return nil
+	}
+	if line == e.Line {
+		return e
 	}
 
-	h.closeFile()
-	return
+	trimmed := *e
+	trimmed.Line = line
+	return &trimmed
 }
 
-// Closes the file descriptor.
-func (h *history) closeFile() {
-	h.file.Close()
+// Returns the number of lines.
+func (h *MemHistory) Len() int { return h.li.Len() }
+
+// Returns the line at position i, where 0 is the oldest one.
+func (h *MemHistory) At(i int) (line string, err error) {
+	if e := h.element(i); e != nil {
+		return e.Value.(*HistoryEntry).Line, nil
+	}
+	return "", ErrNilElement
 }
 
-// Re-opens the file.
-/*func (h *history) openFile() {
-	file, err := os.Open(fname, os.O_CREATE|os.O_RDWR, HistoryPerm)
-	if err != nil {
-		log.Println("history.openFile:", err)
-		return
+// Returns the entry at position i, where 0 is the oldest one.
+func (h *MemHistory) Entry(i int) (*HistoryEntry, error) {
+	if e := h.element(i); e != nil {
+		return e.Value.(*HistoryEntry), nil
 	}
+	return nil, ErrNilElement
+}
 
-	h.file = file
-}*/
-// ===
+// Returns the element at position i, or nil if it is out of range.
+func (h *MemHistory) element(i int) *list.Element {
+	if i < 0 || i >= h.li.Len() {
+		return nil
+	}
 
-// Adds a new line to the buffer.
-func (h *history) Add(line string) {
-	if h.li.Len() <= h.Cap {
-		h.mark = h.li.PushBack(line)
-	} else {
-		// TODO: overwrite lines
+	// Walk from the nearest end.
+	if i < h.li.Len()/2 {
+		e := h.li.Front()
+		for ; i > 0; i-- {
+			e = e.Next()
+		}
+		return e
+	}
+
+	e := h.li.Back()
+	for i = h.li.Len() - 1 - i; i > 0; i-- {
+		e = e.Prev()
 	}
+	return e
+}
+
+// Searches backward from the line previous to the cursor the first line which
+// contains the substring, moving the cursor to it. Returns its position.
+func (h *MemHistory) Search(substr string) (i int, err error) {
+	if h.li.Len() <= 0 {
+		return 0, ErrEmptyHist
+	}
+
+	// Position of the cursor.
+	i = h.li.Len()
+	e := h.li.Back()
+	if h.mark != nil {
+		for i--; e != h.mark; e = e.Prev() {
+			i--
+		}
+		e = e.Prev()
+	}
+
+	for ; e != nil; e = e.Prev() {
+		i--
+		if strings.Contains(e.Value.(*HistoryEntry).Line, substr) {
+			h.mark = e
+			return i, nil
+		}
+	}
+	return 0, ErrNilElement
+}
+
+// Sets the cursor after of the last line.
+func (h *MemHistory) Reset() {
+	h.mark = nil
 }
 
 // Base to move between lines.
-func (h *history) _baseNextPrev(c byte) (line []rune, err error) {
+func (h *MemHistory) _baseNextPrev(c byte) (line []rune, err error) {
 	if h.li.Len() <= 0 {
 		return line, ErrEmptyHist
 	}
 
-	new := new(list.Element)
+	var new *list.Element
 	if c == 'p' {
-		new = h.mark.Prev()
+		if h.mark == nil {
+			new = h.li.Back()
+		} else {
+			new = h.mark.Prev()
+		}
 	} else if c == 'n' {
-		new = h.mark.Next()
+		if h.mark != nil {
+			new = h.mark.Next()
+		}
 	} else {
 		panic("history._baseNextPrev: wrong character choice")
 	}
@@ -173,15 +488,61 @@ func (h *history) _baseNextPrev(c byte) (line []rune, err error) {
 		return nil, ErrNilElement
 	}
 
-	return []rune(new.Value.(string)), nil
+	return []rune(new.Value.(*HistoryEntry).Line), nil
+}
+
+// === Cursor of a line
+// ===
+
+// histCursor moves a Line between the lines of the history. The line being
+// edited is kept apart, and the changes done to the lines of the history are
+// remembered while moving between them, as in readline; the history is not
+// modified.
+type histCursor struct {
+	hist  History
+	pos   int            // Position of the line shown; Len() for the line being edited
+	edits map[int][]rune // Lines changed, by position
+}
+
+// Sets the cursor at the line being edited, dropping the changes.
+func (c *histCursor) reset() {
+	c.hist.Reset()
+	c.pos = c.hist.Len()
+	c.edits = make(map[int][]rune)
+}
+
+// Moves the cursor 'n' lines, keeping the actual line as it is shown.
+// Returns the line to show, with the changes done to it.
+//
+// At the first move up from the line being edited, the history is reset to
+// get the lines added by other programs, if it is shared.
+func (c *histCursor) move(n int, actual []rune) (line []rune, err error) {
+	if n < 0 && len(c.edits) == 0 {
+		c.hist.Reset()
+		c.pos = c.hist.Len()
+	}
+
+	to := c.pos + n
+	if to < 0 || to > c.hist.Len() {
+		return nil, ErrNilElement
+	}
+
+	c.edits[c.pos] = append([]rune(nil), actual...)
+	c.pos = to
+
+	if line, ok := c.edits[to]; ok {
+		return line, nil
+	}
+	s, err := c.hist.At(to)
+	return []rune(s), err
 }
 
 // Returns the previous line.
-func (h *history) Prev() (line []rune, err error) {
+func (h *MemHistory) Prev() (line []rune, err error) {
 	return h._baseNextPrev('p')
 }
 
 // Returns the next line.
-func (h *history) Next() (line []rune, err error) {
+func (h *MemHistory) Next() (line []rune, err error) {
 	return h._baseNextPrev('n')
 }
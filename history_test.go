@@ -10,10 +10,17 @@
 package linoise
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -56,7 +63,7 @@ func TestHistLoad(t *testing.T) {
 	e := hist.li.Front()
 
 	for i := 0; i < hist.li.Len(); i++ {
-		line := e.Value.(string)
+		line := e.Value.(*HistoryEntry).Line
 
 		if strings.HasSuffix(line, "\n") || strings.HasSuffix(line, "\t") ||
 			strings.HasSuffix(line, " ") {
@@ -69,4 +76,637 @@ func TestHistLoad(t *testing.T) {
 	}
 
 	os.Remove(historyFile)
+	os.Remove(historyFile + ".lock")
+}
+
+func TestHistEvict(t *testing.T) {
+	fname := historyFile + "_evict"
+	defer os.Remove(fname)
+	defer os.Remove(fname + ".lock")
+
+	hist, err := NewHistorySize(fname, 3)
+	if err != nil {
+		t.Fatal("could not create history", err)
+	}
+	hist.FileCap = 5
+
+	for i := 1; i <= 7; i++ {
+		hist.Add("line " + strconv.Itoa(i))
+	}
+
+	if hist.li.Len() != 3 {
+		t.Errorf("length in memory: got %d, want 3", hist.li.Len())
+	}
+	if line := hist.li.Front().Value.(*HistoryEntry).Line; line != "line 5" {
+		t.Errorf("oldest line not evicted: %q", line)
+	}
+	hist.Save()
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "line 3\nline 4\nline 5\nline 6\nline 7\n"
+	if string(data) != want {
+		t.Errorf("file saved:\ngot  %q\nwant %q", data, want)
+	}
+
+	// The file is loaded until the capacity in memory.
+	if hist, err = NewHistorySize(fname, 3); err != nil {
+		t.Fatal("could not load history", err)
+	}
+	hist.Load()
+
+	if hist.li.Len() != 3 || hist.li.Back().Value.(*HistoryEntry).Line != "line 7" {
+		t.Errorf("history loaded with %d lines", hist.li.Len())
+	}
+}
+
+func TestMemHistory(t *testing.T) {
+	var hist History = NewMemHistory()
+
+	for _, line := range []string{"ls", "cd /tmp", "ls -l", "make"} {
+		hist.Add(line)
+	}
+
+	if hist.Len() != 4 {
+		t.Errorf("length: got %d, want 4", hist.Len())
+	}
+	if line, err := hist.At(1); err != nil || line != "cd /tmp" {
+		t.Errorf("At(1): got %q, %v", line, err)
+	}
+	if _, err := hist.At(4); err != ErrNilElement {
+		t.Error("At(4): expected error for a line out of range")
+	}
+
+	if i, err := hist.Search("ls"); err != nil || i != 2 {
+		t.Errorf("Search(\"ls\"): got %d, %v", i, err)
+	}
+	if i, err := hist.Search("ls"); err != nil || i != 0 {
+		t.Errorf("Search(\"ls\") again: got %d, %v", i, err)
+	}
+	if _, err := hist.Search("ls"); err != ErrNilElement {
+		t.Error("Search: expected error when there are no more lines")
+	}
+
+	var nilHist *FileHistory
+	if hasHistory(nilHist) || hasHistory(nil) || !hasHistory(hist) {
+		t.Error("hasHistory: nil pointers are not histories")
+	}
+
+	// A capacity set to 0 is the one by default.
+	empty := NewMemHistory()
+	empty.Cap = 0
+	empty.Add("ls")
+	if empty.Len() != 1 {
+		t.Errorf("capacity 0: length %d, want 1", empty.Len())
+	}
+}
+
+func TestHistSaveAtomic(t *testing.T) {
+	fname := historyFile + "_atomic"
+	defer os.Remove(fname)
+	defer os.Remove(fname + ".lock")
+
+	hist, err := NewHistory(fname)
+	if err != nil {
+		t.Fatal("could not create history", err)
+	}
+
+	for _, line := range []string{"a long line", "another long line", "last"} {
+		hist.Add(line)
+	}
+	if err = hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A shorter history does not leave lines of the previous one.
+	if hist, err = NewHistorySize(fname, 2); err != nil {
+		t.Fatal(err)
+	}
+	hist.Load()
+	hist.Add("x")
+	if err = hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+	hist.Add("y")
+	if err = hist.Save(); err != nil {
+		t.Fatal("could not save twice:", err)
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "x\ny\n" {
+		t.Errorf("file saved: got %q", data)
+	}
+
+	info, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != os.FileMode(HistoryPerm) {
+		t.Errorf("permission: got %o, want %o", perm, HistoryPerm)
+	}
+
+	// No temporary files are left.
+	tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp*"))
+	if len(tmp) != 0 {
+		t.Errorf("temporary files left: %v", tmp)
+	}
+}
+
+func TestHistMerge(t *testing.T) {
+	fname := historyFile + "_merge"
+	defer os.Remove(fname)
+	defer os.Remove(fname + ".lock")
+
+	// === Saving of two sessions.
+	a, _ := NewHistory(fname)
+	b, _ := NewHistory(fname)
+	a.Load()
+	b.Load()
+
+	a.Add("a1")
+	b.Add("b1")
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(fname)
+	if string(data) != "a1\nb1\n" {
+		t.Errorf("lines of a session lost: got %q", data)
+	}
+
+	// === Appending and sharing.
+	c, _ := NewHistory(fname)
+	d, _ := NewHistory(fname)
+	c.Append, c.Share = true, true
+	d.Append, d.Share = true, true
+	c.Load()
+	d.Load()
+
+	c.Add("c1")
+	d.Add("d1")
+
+	// A line of d is being edited when the line of c is added.
+	cursor := histCursor{hist: d}
+	cursor.reset()
+	c.Add("c2")
+
+	data, _ = ioutil.ReadFile(fname)
+	if string(data) != "a1\nb1\nc1\nd1\nc2\n" {
+		t.Errorf("lines appended: got %q", data)
+	}
+
+	if line, err := cursor.move(-1, nil); err != nil || string(line) != "c2" {
+		t.Errorf("lines of other session not got at moving up: got %q, %v", string(line), err)
+	}
+
+	d.Prev()
+	if line, _ := d.At(d.Len() - 1); d.Len() != 5 || line != "c2" {
+		t.Errorf("lines of other session not got: length %d, last %q", d.Len(), line)
+	}
+
+	// === Deduplicating at loading.
+	e, _ := NewHistory(fname)
+	e.Add("b1")
+	if err := e.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := e.At(e.Len() - 1); e.Len() != 5 || line != "b1" {
+		t.Errorf("merge at loading: length %d, last %q", e.Len(), line)
+	}
+}
+
+func TestHistFormat(t *testing.T) {
+	fname := historyFile + "_format"
+	defer os.Remove(fname)
+	defer os.Remove(fname + ".lock")
+
+	when := time.Unix(1300000000, 0)
+
+	for _, format := range []HistoryFormat{HistoryZsh, HistoryBash} {
+		hist, err := NewHistory(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hist.Format = format
+
+		hist.AddEntry(HistoryEntry{Line: "make", Time: when, Duration: 3 * time.Second,
+			Dir: "/home/go dev", Status: 2, Session: "s1"})
+		if err = hist.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		if hist, err = NewHistory(fname); err != nil {
+			t.Fatal(err)
+		}
+		hist.Format = format
+		hist.Load()
+
+		e, err := hist.Entry(0)
+		if err != nil || hist.Len() != 1 {
+			t.Fatalf("format %d: %d entries loaded, %v", format, hist.Len(), err)
+		}
+		if e.Line != "make" || !e.Time.Equal(when) || e.Duration != 3*time.Second {
+			t.Errorf("format %d: entry loaded: %+v", format, e)
+		}
+		if format == HistoryBash && (e.Dir != "/home/go dev" || e.Status != 2 || e.Session != "s1") {
+			t.Errorf("format %d: extra fields loaded: %+v", format, e)
+		}
+		os.Remove(fname)
+	}
+
+	// === Plain lines are read together with the extended ones.
+	data := "ls\n: 1300000000:0;pwd\n#1300000000\ncd\n"
+	if err := ioutil.WriteFile(fname, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	hist, _ := NewHistory(fname)
+	hist.Format = HistoryBash
+	hist.Load()
+
+	for i, want := range []string{"ls", "pwd", "cd"} {
+		if line, _ := hist.At(i); line != want {
+			t.Errorf("line %d: got %q, want %q", i, line, want)
+		}
+	}
+	if hist.Len() != 3 {
+		t.Errorf("length: got %d, want 3", hist.Len())
+	}
+
+	// === Lines which look like the extended formats are kept as they are.
+	like := []string{"#1 todo", "ls", ": 12:0;echo"}
+
+	for _, format := range []HistoryFormat{HistoryPlain, HistoryZsh, HistoryBash} {
+		os.Remove(fname)
+		hist, _ = NewHistory(fname)
+		hist.Format = format
+		for _, line := range like {
+			hist.Add(line)
+		}
+		if err := hist.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		hist, _ = NewHistory(fname)
+		hist.Format = format
+		hist.Load()
+
+		got := make([]string, hist.Len())
+		for i := range got {
+			got[i], _ = hist.At(i)
+		}
+		if !reflect.DeepEqual(got, like) {
+			t.Errorf("format %d: got %q, want %q", format, got, like)
+		}
+	}
+}
+
+func TestHistMultiline(t *testing.T) {
+	fname := historyFile + "_multiline"
+	defer os.Remove(fname)
+	defer os.Remove(fname + ".lock")
+
+	lines := []string{
+		"for i in 1 2\ndo echo $i\ndone",
+		`echo a\nb \\ c\`,
+		"plain",
+	}
+
+	for _, appending := range []bool{false, true} {
+		os.Remove(fname)
+
+		// A file with lines not escaped, to check that it is converted.
+		ioutil.WriteFile(fname, []byte(`grep "a\n"`+"\n"), 0600)
+
+		hist, err := NewHistory(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hist.Append = appending
+		hist.Load()
+
+		for _, line := range lines {
+			hist.Add(line)
+		}
+		if err = hist.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		if hist, err = NewHistory(fname); err != nil {
+			t.Fatal(err)
+		}
+		hist.Load()
+
+		want := append([]string{`grep "a\n"`}, lines...)
+		if hist.Len() != len(want) {
+			t.Fatalf("append %v: got %d lines, want %d", appending, hist.Len(), len(want))
+		}
+		for i, w := range want {
+			if line, _ := hist.At(i); line != w {
+				t.Errorf("append %v: line %d: got %q, want %q", appending, i, line, w)
+			}
+		}
+	}
+}
+
+func TestHistControl(t *testing.T) {
+	hist := NewMemHistory()
+	hist.Control = IgnoreBoth | EraseDups
+	hist.IgnorePatterns = []string{"ls", "[bf]g", "cd *", "&"}
+	hist.IgnoreFunc = func(line string) bool {
+		return strings.Contains(line, "password")
+	}
+
+	for _, line := range []string{
+		"make", "make", " secret", "ls", "bg", "fg", "cd /tmp",
+		"mysql --password=x", "vi", "make", "ls -l",
+	} {
+		hist.Add(line)
+	}
+
+	want := []string{"vi", "make", "ls -l"}
+	if hist.Len() != len(want) {
+		t.Fatalf("length: got %d, want %d", hist.Len(), len(want))
+	}
+	for i, w := range want {
+		if line, _ := hist.At(i); line != w {
+			t.Errorf("line %d: got %q, want %q", i, line, w)
+		}
+	}
+
+	for _, tt := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "", true},
+		{"a*c", "abbc", true},
+		{"a*c", "abcd", false},
+		{"?b", "ab", true},
+		{"[a-c]x", "bx", true},
+		{"[!a-c]x", "bx", false},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"[ab", "[ab", true},
+	} {
+		if got := matchGlob(tt.pattern, tt.s); got != tt.match {
+			t.Errorf("matchGlob(%q, %q): got %v", tt.pattern, tt.s, got)
+		}
+	}
+}
+
+func TestHistCursor(t *testing.T) {
+	hist := NewMemHistory()
+	for _, line := range []string{"a", "b", "c"} {
+		hist.Add(line)
+	}
+
+	if line, err := hist.Prev(); err != nil || string(line) != "c" {
+		t.Errorf("Prev after of adding: got %q, %v", string(line), err)
+	}
+	if _, err := hist.Next(); err != ErrNilElement {
+		t.Error("Next at the last line: expected error")
+	}
+	hist.Reset()
+	if _, err := hist.Next(); err != ErrNilElement {
+		t.Error("Next after of Reset: expected error")
+	}
+
+	// === Cursor of a line
+	c := histCursor{hist: hist}
+	c.reset()
+
+	move := func(n int, actual, want string) {
+		line, err := c.move(n, []rune(actual))
+		if err != nil || string(line) != want {
+			t.Errorf("move(%d): got %q, %v; want %q", n, string(line), err, want)
+		}
+	}
+
+	move(-1, "scratch", "c")
+	move(-1, "c edited", "b")
+	move(1, "b", "c edited")
+	move(1, "c edited", "scratch")
+	if _, err := c.move(1, []rune("scratch")); err != ErrNilElement {
+		t.Error("move after of the line being edited: expected error")
+	}
+
+	if hist.Len() != 3 {
+		t.Errorf("history modified: length %d", hist.Len())
+	}
+	if line, _ := hist.At(2); line != "c" {
+		t.Errorf("history modified: last line %q", line)
+	}
+
+	c.reset()
+	move(-1, "", "c")
+}
+
+func TestHistQuery(t *testing.T) {
+	hist := NewMemHistory()
+	for _, line := range []string{
+		"git checkout master",
+		"make install",
+		"go test",
+		"git commit",
+		"grep -c o",
+	} {
+		hist.Add(line)
+	}
+
+	equal := func(name string, got, want []int) {
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+			return
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: got %v, want %v", name, got, want)
+				return
+			}
+		}
+	}
+
+	equal("Find", hist.Find("git"), []int{0, 3})
+	equal("FindRegexp", hist.FindRegexp(regexp.MustCompile(`^g\w+ t`)), []int{2})
+	equal("FindFuzzy", hist.FindFuzzy("gco"), []int{3, 4, 0})
+
+	if _, pos, ok := fuzzyMatch("mkin", "make install"); !ok {
+		t.Error("fuzzyMatch: expected match")
+	} else {
+		equal("fuzzyMatch", pos, []int{0, 2, 5, 6})
+	}
+	if _, _, ok := fuzzyMatch("xyz", "make install"); ok {
+		t.Error("fuzzyMatch: expected no match")
+	}
+
+	// A later start of word which does not leave the rest of the pattern.
+	for _, tt := range []struct {
+		pattern, text string
+		pos           []int
+	}{
+		{"st", "test s", []int{2, 3}},
+		{"ab", "xab a", []int{1, 2}},
+	} {
+		if _, pos, ok := fuzzyMatch(tt.pattern, tt.text); !ok {
+			t.Errorf("fuzzyMatch(%q, %q): expected match", tt.pattern, tt.text)
+		} else {
+			equal("fuzzyMatch "+tt.text, pos, tt.pos)
+		}
+	}
+
+	other := NewMemHistory()
+	other.Add("test s")
+	equal("FindFuzzy at the end", other.FindFuzzy("st"), []int{0})
+
+	lines := make([]string, 0)
+	hist.Each(func(i int, e *HistoryEntry) bool {
+		lines = append(lines, e.Line)
+		return i < 1
+	})
+	if len(lines) != 2 || lines[1] != "make install" {
+		t.Errorf("Each: got %q", lines)
+	}
+
+	if err := hist.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := hist.Delete(9); err != ErrNilElement {
+		t.Error("Delete(9): expected error")
+	}
+
+	var buf bytes.Buffer
+	if err := hist.Export(&buf, HistoryPlain); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "git checkout master\ngo test\ngit commit\ngrep -c o\n" {
+		t.Errorf("Export: got %q", buf.String())
+	}
+
+	hist.Clear()
+	if hist.Len() != 0 {
+		t.Errorf("Clear: length %d", hist.Len())
+	}
+}
+
+func TestHistDir(t *testing.T) {
+	base, err := ioutil.TempDir("", "linoise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	os.Setenv("XDG_STATE_HOME", filepath.Join(base, "state"))
+	defer os.Unsetenv("XDG_STATE_HOME")
+
+	d, err := NewHistoryDir("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Dir != filepath.Join(base, "state", "app") {
+		t.Errorf("directory: got %q", d.Dir)
+	}
+	if info, err := os.Stat(d.Dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("directory not created with permission 0700: %v, %v", info, err)
+	}
+
+	d.Cap = 2
+	sql, err := d.Open("sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := d.Open("admin")
+	if same, _ := d.Open("sql"); same != sql {
+		t.Error("Open: got another history for the same name")
+	}
+	if sql.Cap != 2 {
+		t.Errorf("options not used: capacity %d", sql.Cap)
+	}
+
+	sql.Add("select 1")
+	admin.Add("restart")
+	if err = d.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(filepath.Join(d.Dir, "sql.history"))
+	if string(data) != "select 1\n" {
+		t.Errorf("namespace sql: got %q", data)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(d.Dir, "admin.history"))
+	if string(data) != "restart\n" {
+		t.Errorf("namespace admin: got %q", data)
+	}
+
+	for _, name := range []string{"", "..", "a/b"} {
+		if _, err = d.Open(name); err == nil {
+			t.Errorf("Open(%q): expected error", name)
+		}
+	}
+}
+
+func TestHistEncrypted(t *testing.T) {
+	fname := historyFile + "_encrypted"
+	defer os.Remove(fname)
+	defer os.Remove(fname + ".lock")
+
+	key := []byte("0123456789abcdef")
+
+	// === Migration of a file without encryption.
+	if err := ioutil.WriteFile(fname, []byte("select 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hist, _ := NewHistory(fname)
+	hist.Key = key
+	if err := hist.Load(); err != nil {
+		t.Fatal(err)
+	}
+	hist.Add("select secret")
+	if err := hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(fname)
+	if !strings.HasPrefix(string(data), historyEncrypted) || strings.Contains(string(data), "select") {
+		t.Errorf("file not encrypted: %q", data)
+	}
+
+	hist, _ = NewHistory(fname)
+	hist.Key = key
+	if err := hist.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := hist.At(1); hist.Len() != 2 || line != "select secret" {
+		t.Errorf("encrypted file: length %d, last %q", hist.Len(), line)
+	}
+
+	// === Appending
+	hist.Append = true
+	hist.Add("select 2")
+
+	other, _ := NewHistory(fname)
+	other.Key = key
+	other.Load()
+	if line, _ := other.At(2); other.Len() != 3 || line != "select 2" {
+		t.Errorf("line appended: length %d, last %q", other.Len(), line)
+	}
+
+	// === Wrong keys
+	hist, _ = NewHistory(fname)
+	hist.Key = []byte("fedcba9876543210")
+	if err := hist.Load(); err != ErrHistoryKey {
+		t.Errorf("wrong key: got error %v", err)
+	}
+
+	hist, _ = NewHistory(fname)
+	if err := hist.Load(); err != ErrHistoryNoKey {
+		t.Errorf("no key: got error %v", err)
+	}
 }
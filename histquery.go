@@ -0,0 +1,131 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// === Iteration
+// ===
+
+// Calls f for each entry, from the oldest one, until it returns false.
+func (h *MemHistory) Each(f func(i int, e *HistoryEntry) bool) {
+	i := 0
+	for e := h.li.Front(); e != nil; e = e.Next() {
+		if !f(i, e.Value.(*HistoryEntry)) {
+			return
+		}
+		i++
+	}
+}
+
+// Writes the entries in the format given, from the oldest one. The lines with
+// newlines are escaped, as in the history files.
+func (h *MemHistory) Export(w io.Writer, f HistoryFormat) (err error) {
+	h.Each(func(_ int, e *HistoryEntry) bool {
+		escaped := strings.IndexByte(e.Line, '\n') != -1
+		_, err = io.WriteString(w, formatEntry(e, f, escaped))
+		return err == nil
+	})
+	return
+}
+
+// === Search
+// ===
+
+// Returns the positions of the lines which contain the substring, from the
+// oldest one.
+func (h *MemHistory) Find(substr string) []int {
+	return h.find(func(line string) bool {
+		return strings.Contains(line, substr)
+	})
+}
+
+// Returns the positions of the lines matched by the regular expression, from
+// the oldest one.
+func (h *MemHistory) FindRegexp(re *regexp.Regexp) []int {
+	return h.find(re.MatchString)
+}
+
+// Returns the positions of the lines which have the characters of the pattern
+// in order, as "gco" for "git checkout", ignoring the case. They are sorted
+// from the best match; the newer lines are first when the matches are equal.
+func (h *MemHistory) FindFuzzy(pattern string) []int {
+	matches := fuzzySearch(h, pattern)
+
+	found := make([]int, len(matches))
+	for i, m := range matches {
+		found[i] = m.index
+	}
+	return found
+}
+
+func (h *MemHistory) find(match func(line string) bool) []int {
+	found := make([]int, 0)
+
+	h.Each(func(i int, e *HistoryEntry) bool {
+		if match(e.Line) {
+			found = append(found, i)
+		}
+		return true
+	})
+	return found
+}
+
+// === Removing
+// ===
+
+// Removes the entry at position i, where 0 is the oldest one.
+func (h *MemHistory) Delete(i int) error {
+	e := h.element(i)
+	if e == nil {
+		return ErrNilElement
+	}
+
+	if e == h.mark {
+		h.mark = nil
+	}
+	h.li.Remove(e)
+	return nil
+}
+
+// Removes all entries.
+func (h *MemHistory) Clear() {
+	h.li.Init()
+	h.mark = nil
+}
+
+// Removes the entry at position i, where 0 is the oldest one. The file is
+// changed at saving the history.
+func (h *FileHistory) Delete(i int) error {
+	e, err := h.Entry(i)
+	if err != nil {
+		return err
+	}
+
+	for j, p := range h.pending {
+		if p == e {
+			h.pending = append(h.pending[:j], h.pending[j+1:]...)
+			break
+		}
+	}
+	return h.MemHistory.Delete(i)
+}
+
+// Removes all entries, also the ones that were removed from the buffer but
+// are still saved. The file is changed at saving the history.
+func (h *FileHistory) Clear() {
+	h.older = h.older[:0]
+	h.pending = h.pending[:0]
+	h.MemHistory.Clear()
+}
@@ -0,0 +1,450 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Time to wait for the next character after of ESC, to know if it is the
+// start of an escape sequence or the Esc key by itself.
+var KeyTimeout = 100 * time.Millisecond
+
+// === Type
+// ===
+
+// Identifies the keys that are not characters.
+type KeyCode int
+
+const (
+	KeyRune    KeyCode = iota // A character, given in the field Rune
+	KeyUnknown                // Escape sequence not recognized
+
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+
+	KeyPasteStart // Start of bracketed paste
+	KeyPasteEnd   // End of bracketed paste
+)
+
+// Modifiers pressed together with a key.
+type KeyMod uint8
+
+const (
+	ModShift KeyMod = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// Represents a key pressed, decoded from the input.
+type Key struct {
+	Code KeyCode
+	Rune rune // Character, when Code is KeyRune
+	Mod  KeyMod
+}
+
+// Returns the key of a character pressed together with Ctrl.
+func CtrlKey(r rune) Key { return Key{KeyRune, unicode.ToLower(r), ModCtrl} }
+
+// Returns the key of a character pressed together with Alt (or Meta).
+func AltKey(r rune) Key { return Key{KeyRune, r, ModAlt} }
+
+// Returns true if the key is a character without modifiers, to be inserted.
+func (k Key) isPrint() bool {
+	return k.Code == KeyRune && k.Mod == 0 && unicode.IsPrint(k.Rune)
+}
+
+// === Decoder
+// ===
+
+var errKeyTimeout = errors.New("key: time out")
+
+// Reads keys from the input.
+type keyReader struct {
+	in      *bufio.Reader
+	pending chan runeResult // Character being read when it was got a time out.
+}
+
+type runeResult struct {
+	r   rune
+	err error
+}
+
+func newKeyReader(r io.Reader) *keyReader {
+	return &keyReader{in: bufio.NewReader(r)}
+}
+
+// The input is read by a single reader so the characters typed in advance are
+// not lost between calls.
+var keyIn *keyReader
+
+// Reads the next key from the input.
+func readKey() (Key, error) {
+	if keyIn == nil {
+		keyIn = newKeyReader(input)
+	}
+	return keyIn.readKey()
+}
+
+// Reads a character. If 'timeout' is not zero, it returns errKeyTimeout when
+// there is nothing to read after of that time; the character will be got by
+// the next call.
+func (kr *keyReader) readRune(timeout time.Duration) (rune, error) {
+	if kr.pending == nil {
+		if timeout == 0 || kr.in.Buffered() != 0 {
+			r, _, err := kr.in.ReadRune()
+			return r, err
+		}
+
+		kr.pending = make(chan runeResult, 1)
+		go func(c chan runeResult) {
+			r, _, err := kr.in.ReadRune()
+			c <- runeResult{r, err}
+		}(kr.pending)
+	}
+
+	var res runeResult
+
+	if timeout == 0 {
+		res = <-kr.pending
+	} else {
+		select {
+		case res = <-kr.pending:
+		case <-time.After(timeout):
+			return 0, errKeyTimeout
+		}
+	}
+
+	kr.pending = nil
+	return res.r, res.err
+}
+
+// Reads and decodes a key.
+func (kr *keyReader) readKey() (Key, error) {
+	r, err := kr.readRune(0)
+	if err != nil {
+		return Key{}, inputError(err.Error())
+	}
+	if r != 27 {
+		return runeKey(r), nil
+	}
+
+	// === Escape sequence
+	r, err = kr.readRune(KeyTimeout)
+	if err == errKeyTimeout || err == io.EOF {
+		return Key{Code: KeyEscape}, nil
+	}
+	if err != nil {
+		return Key{}, inputError(err.Error())
+	}
+
+	switch r {
+	case '[':
+		return kr.readCSI()
+	case 'O':
+		return kr.readSS3()
+	}
+
+	// Alt is sent as ESC before of the key.
+	k := runeKey(r)
+	k.Mod |= ModAlt
+	return k, nil
+}
+
+// Returns the key for a character read without escape sequence.
+func runeKey(r rune) Key {
+	switch r {
+	case 13:
+		return Key{Code: KeyEnter}
+	case 9:
+		return Key{Code: KeyTab}
+	case 127:
+		return Key{Code: KeyBackspace}
+	case 27:
+		return Key{Code: KeyEscape}
+	}
+
+	if r < 32 { // Ctrl-@ to Ctrl-_
+		return CtrlKey(r + '@')
+	}
+	return Key{Code: KeyRune, Rune: r}
+}
+
+// Reads a "Control Sequence Introducer" sequence, after of "ESC [". They are
+// formed by parameters separated by ';', and a final character.
+func (kr *keyReader) readCSI() (Key, error) {
+	var seq []rune
+
+	for {
+		r, err := kr.readRune(0)
+		if err != nil {
+			return Key{}, inputError(err.Error())
+		}
+		seq = append(seq, r)
+
+		// The console of Linux sends "ESC [ [ A" for F1.
+		if len(seq) == 1 && r == '[' {
+			continue
+		}
+		if r >= 0x40 && r <= 0x7e { // Final character
+			break
+		}
+		if r < 0x20 || r > 0x3f { // Not a valid parameter
+			return Key{Code: KeyUnknown}, nil
+		}
+	}
+
+	if k, ok := seqKeys["\033["+string(seq)]; ok {
+		return k, nil
+	}
+
+	final := seq[len(seq)-1]
+	params := strings.Split(string(seq[:len(seq)-1]), ";")
+	num := make([]int, len(params))
+
+	for i, p := range params {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return Key{Code: KeyUnknown}, nil
+		}
+		num[i] = n
+	}
+
+	k := Key{Code: KeyUnknown}
+
+	if final == '~' {
+		switch num[0] {
+		case 1, 7:
+			k.Code = KeyHome
+		case 2:
+			k.Code = KeyInsert
+		case 3:
+			k.Code = KeyDelete
+		case 4, 8:
+			k.Code = KeyEnd
+		case 5:
+			k.Code = KeyPageUp
+		case 6:
+			k.Code = KeyPageDown
+		case 11, 12, 13, 14, 15:
+			k.Code = KeyF1 + KeyCode(num[0]-11)
+		case 17, 18, 19, 20, 21:
+			k.Code = KeyF6 + KeyCode(num[0]-17)
+		case 23, 24:
+			k.Code = KeyF11 + KeyCode(num[0]-23)
+		case 200:
+			k.Code = KeyPasteStart
+		case 201:
+			k.Code = KeyPasteEnd
+		}
+	} else {
+		k.Code = finalKey(final)
+
+		if final == 'Z' { // Shift-Tab
+			k.Code = KeyTab
+			k.Mod = ModShift
+		}
+	}
+
+	// The modifiers are the second parameter, as "ESC [ 1 ; 5 C" (Ctrl-Right).
+	if len(num) > 1 {
+		k.Mod |= paramMod(num[1])
+	}
+	return k, nil
+}
+
+// Reads a "Single Shift Three" sequence, after of "ESC O", sent by the
+// keypad in application mode. Some terminals send the modifiers before of the
+// final character.
+func (kr *keyReader) readSS3() (Key, error) {
+	var mod KeyMod
+
+	r, err := kr.readRune(0)
+	if err != nil {
+		return Key{}, inputError(err.Error())
+	}
+
+	if k, ok := seqKeys["\033O"+string(r)]; ok {
+		return k, nil
+	}
+
+	for r >= '0' && r <= '9' {
+		mod = paramMod(int(r - '0'))
+
+		if r, err = kr.readRune(0); err != nil {
+			return Key{}, inputError(err.Error())
+		}
+	}
+
+	k := Key{Code: finalKey(r), Mod: mod}
+	if r == 'M' { // Enter in the keypad
+		k.Code = KeyEnter
+	}
+	return k, nil
+}
+
+// Returns the key for the final character of CSI and SS3 sequences.
+func finalKey(final rune) KeyCode {
+	switch final {
+	case 'A':
+		return KeyUp
+	case 'B':
+		return KeyDown
+	case 'C':
+		return KeyRight
+	case 'D':
+		return KeyLeft
+	case 'H':
+		return KeyHome
+	case 'F':
+		return KeyEnd
+	case 'P', 'Q', 'R', 'S':
+		return KeyF1 + KeyCode(final-'P')
+	}
+	return KeyUnknown
+}
+
+// Returns the modifiers coded in a parameter, which is 1 plus the bits of
+// Shift (1), Alt (2) and Ctrl (4).
+func paramMod(p int) (mod KeyMod) {
+	if p < 2 {
+		return 0
+	}
+	p--
+
+	if p&1 != 0 {
+		mod |= ModShift
+	}
+	if p&2 != 0 {
+		mod |= ModAlt
+	}
+	if p&4 != 0 {
+		mod |= ModCtrl
+	}
+	return
+}
+
+// Escape sequences of keys that are not decoded by the generic way.
+var seqKeys = map[string]Key{}
+
+// === Bindings
+// ===
+
+// Editing actions that can be bound to keys.
+type Action int
+
+const (
+	ActionNone Action = iota // Ignores the key
+
+	ActionAcceptLine
+	ActionInterrupt       // Ctrl-C
+	ActionDeleteCharOrEOF // Ctrl-D
+	ActionSuspend         // Ctrl-Z
+	ActionUndo
+
+	ActionBackwardChar
+	ActionForwardChar
+	ActionBeginningOfLine
+	ActionEndOfLine
+
+	ActionPreviousHistory
+	ActionNextHistory
+	ActionFindHistory // Fuzzy finder of the history
+
+	ActionBackwardDeleteChar
+	ActionDeleteChar
+	ActionKillLine // Delete from cursor to the end of line
+	ActionKillWholeLine
+	ActionTransposeChars
+)
+
+// Keys bound by default, as in Emacs mode of readline.
+var defaultKeys = map[Key]Action{
+	Key{Code: KeyEnter}: ActionAcceptLine,
+	CtrlKey('j'):        ActionAcceptLine,
+	Key{Code: KeyTab}:   ActionNone, // TODO: disabled by now
+
+	CtrlKey('c'): ActionInterrupt,
+	CtrlKey('d'): ActionDeleteCharOrEOF,
+	CtrlKey('z'): ActionSuspend,
+	CtrlKey('_'): ActionUndo,
+
+	Key{Code: KeyLeft}:  ActionBackwardChar,
+	CtrlKey('b'):        ActionBackwardChar,
+	Key{Code: KeyRight}: ActionForwardChar,
+	CtrlKey('f'):        ActionForwardChar,
+	Key{Code: KeyHome}:  ActionBeginningOfLine,
+	CtrlKey('a'):        ActionBeginningOfLine,
+	Key{Code: KeyEnd}:   ActionEndOfLine,
+	CtrlKey('e'):        ActionEndOfLine,
+
+	Key{Code: KeyUp}:   ActionPreviousHistory,
+	CtrlKey('p'):       ActionPreviousHistory,
+	Key{Code: KeyDown}: ActionNextHistory,
+	CtrlKey('n'):       ActionNextHistory,
+	CtrlKey('r'):       ActionFindHistory,
+
+	Key{Code: KeyBackspace}: ActionBackwardDeleteChar,
+	CtrlKey('h'):            ActionBackwardDeleteChar,
+	Key{Code: KeyDelete}:    ActionDeleteChar,
+	CtrlKey('k'):            ActionKillLine,
+	CtrlKey('u'):            ActionKillWholeLine,
+	CtrlKey('t'):            ActionTransposeChars,
+}
+
+// Binds the key to an action, replacing the previous one. Use ActionNone to
+// ignore a key.
+func (ln *Line) Bind(k Key, a Action) {
+	ln.keys[k] = a
+}
+
+// Returns a copy of the keys bound by default.
+func newKeyMap() map[Key]Action {
+	keys := make(map[Key]Action, len(defaultKeys))
+	for k, a := range defaultKeys {
+		keys[k] = a
+	}
+	return keys
+}
@@ -0,0 +1,73 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var keyTests = []struct {
+	in   string
+	keys []Key
+}{
+	{"a\xc3\xb1", []Key{{Code: KeyRune, Rune: 'a'}, {Code: KeyRune, Rune: 'ñ'}}},
+	{"\r\t\x7f", []Key{{Code: KeyEnter}, {Code: KeyTab}, {Code: KeyBackspace}}},
+	{"\x01\x08\x1f", []Key{CtrlKey('a'), CtrlKey('h'), CtrlKey('_')}},
+
+	{"\x1b[A\x1b[D", []Key{{Code: KeyUp}, {Code: KeyLeft}}},
+	{"\x1b[1;5C\x1b[1;3D", []Key{{Code: KeyRight, Mod: ModCtrl}, {Code: KeyLeft, Mod: ModAlt}}},
+	{"\x1b[3~\x1b[3;2~", []Key{{Code: KeyDelete}, {Code: KeyDelete, Mod: ModShift}}},
+	{"\x1b[1~\x1b[4~\x1b[H\x1b[F", []Key{{Code: KeyHome}, {Code: KeyEnd}, {Code: KeyHome}, {Code: KeyEnd}}},
+	{"\x1b[15~\x1b[24~\x1b[Z", []Key{{Code: KeyF5}, {Code: KeyF12}, {Code: KeyTab, Mod: ModShift}}},
+	{"\x1b[200~", []Key{{Code: KeyPasteStart}}},
+	{"\x1b[99x\x1b[A", []Key{{Code: KeyUnknown}, {Code: KeyUp}}},
+
+	{"\x1bOH\x1bOP\x1bOM\x1bO5A", []Key{{Code: KeyHome}, {Code: KeyF1}, {Code: KeyEnter}, {Code: KeyUp, Mod: ModCtrl}}},
+
+	{"\x1bb\x1b\x7f", []Key{AltKey('b'), {Code: KeyBackspace, Mod: ModAlt}}},
+	{"\x1b", []Key{{Code: KeyEscape}}},
+}
+
+func TestKeyDecode(t *testing.T) {
+	for _, tt := range keyTests {
+		kr := newKeyReader(strings.NewReader(tt.in))
+
+		for i, want := range tt.keys {
+			k, err := kr.readKey()
+			if err != nil {
+				t.Errorf("%q: key %d: %s", tt.in, i, err)
+				break
+			}
+			if k != want {
+				t.Errorf("%q: key %d: got %+v, want %+v", tt.in, i, k, want)
+			}
+		}
+	}
+}
+
+func TestKeyTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	kr := newKeyReader(r)
+
+	go w.Write([]byte{27})
+
+	if k, err := kr.readKey(); err != nil || k.Code != KeyEscape {
+		t.Fatalf("lone Esc: got %+v, %v", k, err)
+	}
+
+	// The character read after of the time out is not lost.
+	go w.Write([]byte("x"))
+
+	if k, err := kr.readKey(); err != nil || k != (Key{Code: KeyRune, Rune: 'x'}) {
+		t.Errorf("after Esc: got %+v, %v", k, err)
+	}
+}
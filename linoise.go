@@ -14,11 +14,13 @@ writing.
 package linoise
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"unicode"
 
 	"github.com/kless/term"
 )
@@ -29,6 +31,23 @@ var (
 	PS2 = "> "
 )
 
+// If it is true, Read returns ErrInterrupted when Ctrl-C is pressed so the
+// caller can cancel the statement or command in course. Else, the line is
+// discarded and it is printed the prompt again.
+var ReturnInterrupt = false
+
+// Values by default for the text pasted from the terminal.
+var (
+	// Enables the bracketed paste mode of the terminal, so the pasted text is
+	// inserted as it is instead of being interpreted as keys.
+	BracketedPaste = true
+
+	// If it is true, a pasted text with several lines is accepted as input
+	// including the newlines. Else, the newlines are changed to spaces so the
+	// text can be edited in the line.
+	PasteMultiline = false
+)
+
 // Input / Output
 var (
 	input  = os.Stdin
@@ -46,6 +65,11 @@ func Init() {
 	if tty, err = term.New(); err != nil {
 		log.Fatalf("%q fd: %d", err, term.InputFD)
 	}
+
+	// The controls by default are ANSI, used when there is no terminfo.
+	if ti, err := loadTerminfo(os.Getenv("TERM")); err == nil {
+		ti.setup()
+	}
 }
 
 // === Type
@@ -54,48 +78,56 @@ func Init() {
 // Represents a line.
 type Line struct {
 	useHistory bool
-	ps1Len     int      // Primary prompt size
-	ps1        string   // Primary prompt
-	ps2        string   // Command continuations
-	buf        *buffer  // Text buffer
-	hist       *history // History file
+	expand     bool           // Expand the references to the history
+	interrupt  bool           // Return ErrInterrupted at pressing Ctrl-C
+	paste      bool           // Use bracketed paste mode
+	pasteLines bool           // Accept pasted text with several lines
+	ps1Len     int            // Primary prompt size
+	ps1        string         // Primary prompt
+	ps2        string         // Command continuations
+	ctrlC      string         // String echoed at pressing Ctrl-C
+	ctrlD      string         // String echoed at pressing Ctrl-D
+	keys       map[Key]Action // Actions bound to keys
+	buf        *buffer        // Text buffer
+	hist       History        // History of lines
+	cursor     histCursor     // Position in the history
 }
 
-// Gets a line type using the primary prompt by default. Sets the TTY raw mode.
-func NewLine(hist *history) *Line {
+// Base to create a line.
+func _baseLine(prompt string, ansiLen int, hist History) *Line {
 	// TODO(jwall): check errors?
 	tty.RawMode()
 
-	buf := newBuffer(len(PS1))
-	buf.insertRunes([]rune(PS1))
+	buf := newBuffer(len(prompt) - ansiLen)
+	buf.insertRunes([]rune(prompt))
 
 	return &Line{
-		hasHistory(hist),
-		len(PS1),
-		PS1,
-		PS2,
-		buf,
-		hist,
+		useHistory: hasHistory(hist),
+		expand:     HistoryExpand,
+		interrupt:  ReturnInterrupt,
+		paste:      BracketedPaste,
+		pasteLines: PasteMultiline,
+		ps1Len:     len(prompt) - ansiLen,
+		ps1:        prompt,
+		ps2:        PS2,
+		ctrlC:      CtrlCString,
+		ctrlD:      CtrlDString,
+		keys:       newKeyMap(),
+		buf:        buf,
+		hist:       hist,
+		cursor:     histCursor{hist: hist},
 	}
 }
 
+// Gets a line type using the primary prompt by default. Sets the TTY raw mode.
+func NewLine(hist History) *Line {
+	return _baseLine(PS1, 0, hist)
+}
+
 // Gets a line type using the given prompt as primary. Sets the TTY raw mode.
 // 'ansiLen' is the length of ANSI codes that the prompt could have.
-func NewLinePrompt(prompt string, ansiLen int, hist *history) *Line {
-	// TODO(jwall): check errors?
-	tty.RawMode()
-
-	buf := newBuffer(len(prompt) - ansiLen)
-	buf.insertRunes([]rune(prompt))
-
-	return &Line{
-		hasHistory(hist),
-		len(prompt) - ansiLen,
-		prompt,
-		PS2,
-		buf,
-		hist,
-	}
+func NewLinePrompt(prompt string, ansiLen int, hist History) *Line {
+	return _baseLine(prompt, ansiLen, hist)
 }
 
 // Restores terminal settings so it is disabled the raw mode.
@@ -103,14 +135,6 @@ func (ln *Line) RestoreTerm() {
 	tty.Restore()
 }
 
-// Tests if it has an history file.
-func hasHistory(h *history) bool {
-	if h == nil {
-		return false
-	}
-	return true
-}
-
 // === Output
 // ===
 
@@ -127,24 +151,217 @@ func (ln *Line) prompt() (err error) {
 	return
 }
 
+// Accepts the line as input, adding it to the history.
+func (ln *Line) accept(line string) (string, error) {
+	if ln.useHistory {
+		ln.hist.Add(line)
+	}
+	if _, err := output.Write(_CR_LF); err != nil {
+		return "", outputError(err.Error())
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// Expands the references to the history of the line, writing the line
+// expanded. If it fails, the error is written and the line is edited again,
+// returning false.
+func (ln *Line) expandLine(line string) (expanded string, ok bool, err error) {
+	if !ln.expand || !ln.useHistory {
+		return line, true, nil
+	}
+
+	expanded, changed, errExpand := expandHistory(line, ln.hist)
+	if errExpand == nil && !changed {
+		return line, true, nil
+	}
+
+	if _, err = ln.buf.end(); err != nil {
+		return "", false, err
+	}
+	if _, err = output.Write(_CR_LF); err != nil {
+		return "", false, outputError(err.Error())
+	}
+
+	if errExpand == nil {
+		if _, err = fmt.Fprint(output, expanded); err != nil {
+			return "", false, outputError(err.Error())
+		}
+		return expanded, true, nil
+	}
+
+	if _, err = fmt.Fprint(output, errExpand.Error(), string(_CR_LF)); err != nil {
+		return "", false, outputError(err.Error())
+	}
+	if err = ln.prompt(); err != nil {
+		return "", false, err
+	}
+	ln.cursor.reset()
+	return "", false, ln.buf.insertText([]rune(line))
+}
+
+// Writes the string echoed for a control key at the end of the line, and goes
+// to a new line.
+func (ln *Line) echoCtrl(s string) (err error) {
+	if _, err = ln.buf.end(); err != nil {
+		return err
+	}
+	if err = ln.buf.insertRunes([]rune(s)); err != nil {
+		return err
+	}
+	if _, err = output.Write(_CR_LF); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
+
+// === Paste
+// ===
+
+// Reads the text pasted from the terminal until the mark of end.
+func readPaste() ([]rune, error) {
+	text := make([]rune, 0, BufferLen)
+	end := []rune(string(pasteEnd))
+
+	for {
+		r, err := keyIn.readRune(0)
+		if err != nil {
+			return nil, inputError(err.Error())
+		}
+		text = append(text, r)
+
+		if n := len(text) - len(end); n >= 0 && string(text[n:]) == string(end) {
+			return text[:n], nil
+		}
+	}
+}
+
+// Inserts the pasted text as a single change of the buffer, so it can be undone
+// at once. Newlines are got as '\n' and any other control character, but
+// tabulators, is removed.
+//
+// If the text has several lines and it is set 'PasteMultiline', then the text
+// is not inserted; it is returned the whole line to be accepted as input.
+func (ln *Line) insertPaste(text []rune) (line string, accept bool, err error) {
+	clean := make([]rune, 0, len(text))
+
+	for i := 0; i < len(text); i++ {
+		switch r := text[i]; {
+		case r == '\r' || r == '\n':
+			if r == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+			if ln.pasteLines {
+				clean = append(clean, '\n')
+				accept = true
+			} else {
+				clean = append(clean, ' ')
+			}
+		case r == '\t' || !unicode.IsControl(r):
+			clean = append(clean, r)
+		}
+	}
+
+	if !accept {
+		ln.buf.saveUndo()
+		return "", false, ln.buf.insertText(clean)
+	}
+
+	line = string(ln.buf.data[ln.buf.promptLen:ln.buf.pos]) + string(clean) +
+		string(ln.buf.data[ln.buf.pos:ln.buf.size])
+
+	// === Rewrite the line using the prompt of continuation for the next lines.
+	if err = ln.buf.deleteLine(); err != nil {
+		return "", false, err
+	}
+	if err = ln.prompt(); err != nil {
+		return "", false, err
+	}
+	if _, err = fmt.Fprint(output,
+		strings.Replace(line, "\n", "\r\n"+ln.ps2, -1)); err != nil {
+		return "", false, outputError(err.Error())
+	}
+
+	return line, true, nil
+}
+
+// === Job control
+// ===
+
+// Suspends the process, as when Ctrl-Z is pressed in cooked mode. The terminal
+// settings are restored before of stopping, and when the process is continued
+// (SIGCONT) it is set the raw mode again and the line is redrawn with the cursor
+// where it was.
+//
+// It does nothing if the signal SIGTSTP is ignored by the program.
+func (ln *Line) suspend() (err error) {
+	if signal.Ignored(syscall.SIGTSTP) {
+		return nil
+	}
+
+	pos := ln.buf.pos
+	if _, err = ln.buf.end(); err != nil {
+		return err
+	}
+	if _, err = output.Write(_CR_LF); err != nil {
+		return outputError(err.Error())
+	}
+
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, syscall.SIGCONT)
+	defer signal.Stop(cont)
+
+	if ln.paste {
+		output.Write(pasteOff)
+	}
+	if err = tty.Restore(); err != nil {
+		return err
+	}
+
+	// Stop the whole process group, like the terminal driver does.
+	if err = syscall.Kill(0, syscall.SIGTSTP); err != nil {
+		tty.RawMode()
+		return err
+	}
+	<-cont // Wait until the shell continues the process.
+
+	if err = tty.RawMode(); err != nil {
+		return err
+	}
+	_, ln.buf.winColumns, _ = tty.GetSize()
+	if ln.paste {
+		output.Write(pasteOn)
+	}
+
+	ln.buf.pos = pos
+	return ln.buf.redraw()
+}
+
 // === Get
 // ===
 
 // Reads charactes from input to write them to output, allowing line editing.
-// The errors that could return are to indicate if Ctrl-D was pressed, and for
-// both input / output errors.
+// The errors that could return are to indicate if Ctrl-D was pressed at an
+// empty line (ErrCtrlD), if Ctrl-C was pressed when it is set ReturnInterrupt
+// (ErrInterrupted), and for both input / output errors.
 func (ln *Line) Read() (line string, err error) {
-	var anotherLine []rune // For lines got from history.
-	var isHistoryUsed bool // If the history has been accessed.
-
-	in := bufio.NewReader(input) // Read input.
-	seq := make([]byte, 2)       // For escape sequences.
-	seq2 := make([]byte, 1)      // Extended escape sequences.
+	var typing bool // If the last key inserted a character.
 
 	// Print the primary prompt.
 	if err = ln.prompt(); err != nil {
 		return "", err
 	}
+	ln.buf.undo = ln.buf.undo[:0]
+	if ln.useHistory {
+		ln.cursor.reset()
+	}
+
+	if ln.paste {
+		if _, err = output.Write(pasteOn); err != nil {
+			return "", outputError(err.Error())
+		}
+		defer output.Write(pasteOff)
+	}
 
 	// === Detect change of window size.
 	wSize := term.DetectWinSize()
@@ -160,210 +377,201 @@ func (ln *Line) Read() (line string, err error) {
 	}()
 
 	for {
-		rune, _, err := in.ReadRune()
+		key, err := readKey()
 		if err != nil {
-			return "", inputError(err.Error())
+			return "", err
 		}
 
-		switch rune {
-		default:
-			if err = ln.buf.insertRune(rune); err != nil {
+		// Consecutive characters are undone at once.
+		wasTyping := typing
+		typing = false
+
+		if key.Code == KeyPasteStart {
+			text, err := readPaste()
+			if err != nil {
 				return "", err
 			}
-			continue
 
-		case 13: // enter
-			line = ln.buf.toString()
-
-			if ln.useHistory {
-				ln.hist.Add(line)
+			line, accept, err := ln.insertPaste(text)
+			if err != nil {
+				return "", err
 			}
-			if _, err = output.Write(_CR_LF); err != nil {
-				return "", outputError(err.Error())
+			if accept {
+				if line, accept, err = ln.expandLine(line); err != nil {
+					return "", err
+				}
+				if accept {
+					return ln.accept(line)
+				}
 			}
+			continue
+		}
 
-			return strings.TrimSpace(line), nil
+		action, ok := ln.keys[key]
+		if !ok {
+			if key.isPrint() {
+				if !wasTyping {
+					ln.buf.saveUndo()
+				}
+				typing = true
 
-		case 127, 8: // backspace, Ctrl-h
-			if err = ln.buf.deletePrev(); err != nil {
-				return "", err
+				if err = ln.buf.insertRune(key.Rune); err != nil {
+					return "", err
+				}
 			}
 			continue
+		}
 
-		case 9: // horizontal tab
-			// TODO: disabled by now
-			continue
+		switch action {
+		case ActionAcceptLine:
+			var ok bool
+			if line, ok, err = ln.expandLine(ln.buf.toString()); err != nil {
+				return "", err
+			}
+			if ok {
+				return ln.accept(line)
+			}
 
-		case 3: // Ctrl-c
-			if err = ln.buf.insertRunes(ctrlC); err != nil {
+		case ActionInterrupt:
+			if err = ln.echoCtrl(ln.ctrlC); err != nil {
 				return "", err
 			}
-			if _, err = output.Write(_CR_LF); err != nil {
-				return "", outputError(err.Error())
+			if ln.interrupt {
+				return "", ErrInterrupted
 			}
 			if err = ln.prompt(); err != nil {
 				return "", err
 			}
 
-			continue
+		// EOF at an empty line; else delete the actual character.
+		case ActionDeleteCharOrEOF:
+			if ln.buf.size != ln.buf.promptLen {
+				ln.buf.saveUndo()
+				if err = ln.buf.delete(); err != nil {
+					return "", err
+				}
+				continue
+			}
 
-		case 4: // Ctrl-d
-			if err = ln.buf.insertRunes(ctrlD); err != nil {
+			if err = ln.echoCtrl(ln.ctrlD); err != nil {
 				return "", err
 			}
-			if _, err = output.Write(_CR_LF); err != nil {
-				return "", outputError(err.Error())
-			}
 
 			return "", ErrCtrlD
 
-		// Escape sequence
-		case 27: // Escape: Ctrl-[ ("033" in octal, "\x1b" in hexadecimal)
-			if _, err = in.Read(seq); err != nil {
-				return "", inputError(err.Error())
+		case ActionSuspend:
+			if err = ln.suspend(); err != nil {
+				return "", err
 			}
 
-			if seq[0] == 79 { // 'O'
-				switch seq[1] {
-				case 72: // Home: "\x1bOH"
-					goto _start
-				case 70: // End: "\x1bOF"
-					goto _end
-				}
+		case ActionUndo:
+			if err = ln.buf.undoLast(); err != nil {
+				return "", err
 			}
 
-			if seq[0] == 91 { // Left square bracket: "["
-				switch seq[1] {
-				case 68: // "\x1b[D"
-					goto _leftArrow
-				case 67: // "\x1b[C"
-					goto _rightArrow
-				case 65, 66: // Up: "\x1b[A"; Down: "\x1b[B"
-					goto _upDownArrow
-				}
-
-				// Extended escape.
-				if seq[1] > 48 && seq[1] < 55 {
-					if _, err = in.Read(seq2); err != nil {
-						return "", inputError(err.Error())
-					}
-
-					if seq2[0] == 126 { // '~'
-						switch seq[1] {
-						//case 50: // Insert: "\x1b[2~"
-
-						case 51: // Delete: "\x1b[3~"
-							if err = ln.buf.delete(); err != nil {
-								return "", err
-							}
-							//case 53: // RePag: "\x1b[5~"
-
-							//case 54: // AvPag: "\x1b[6~"
+		// === Movement
 
-						}
-					}
-				}
-			}
-			continue
-
-		case 20: // Ctrl-t, swap actual character by the previous one.
-			if err = ln.buf.swap(); err != nil {
+		case ActionBackwardChar:
+			if err = ln.buf.backward(); err != nil {
 				return "", err
 			}
-			continue
 
-		case 21: // Ctrl+u, delete the whole line.
-			if err = ln.buf.deleteLine(); err != nil {
+		case ActionForwardChar:
+			if err = ln.buf.forward(); err != nil {
 				return "", err
 			}
-			if err = ln.prompt(); err != nil {
+
+		case ActionBeginningOfLine:
+			if err = ln.buf.start(); err != nil {
 				return "", err
 			}
-			continue
 
-		case 11: // Ctrl+k, delete from current to end of line.
-			if err = ln.buf.deleteRight(); err != nil {
+		case ActionEndOfLine:
+			if _, err = ln.buf.end(); err != nil {
 				return "", err
 			}
-			continue
-
-		case 1: // Ctrl+a, go to the start of the line.
-			goto _start
 
-		case 5: // Ctrl+e, go to the end of the line.
-			goto _end
+		// === History
 
-		case 2: // Ctrl-b
-			goto _leftArrow
+		case ActionPreviousHistory, ActionNextHistory:
+			if !ln.useHistory {
+				continue
+			}
 
-		case 6: // Ctrl-f
-			goto _rightArrow
+			n := 1
+			if action == ActionPreviousHistory {
+				n = -1
+			}
 
-		case 16: // Ctrl-p
-			seq[1] = 65
-			goto _upDownArrow
+			// The line shown is kept, with its changes, by the cursor.
+			var anotherLine []rune
+			anotherLine, err = ln.cursor.move(n, []rune(ln.buf.toString()))
+			if err != nil {
+				continue
+			}
 
-		case 14: // Ctrl-n
-			seq[1] = 66
-			goto _upDownArrow
-		}
+			ln.buf.saveUndo()
+			pos := ln.buf.promptLen + len(anotherLine)
+			if err = ln.buf.replace(anotherLine, pos); err != nil {
+				return "", err
+			}
 
-	_upDownArrow: // Up and down arrow: history
-		if !ln.useHistory {
-			continue
-		}
+		case ActionFindHistory:
+			if !ln.useHistory {
+				continue
+			}
 
-		// Up
-		if seq[1] == 65 {
-			anotherLine, err = ln.hist.Prev()
-			// Down
-		} else {
-			anotherLine, err = ln.hist.Next()
-		}
-		if err != nil {
-			continue
-		}
+			var chosen []rune
+			if chosen, err = ln.find(); err != nil {
+				return "", err
+			}
 
-		// Update the current history entry before to overwrite it with
-		// the next one.
-		// TODO: it has to be removed before of to be saved the history
-		if !isHistoryUsed {
-			ln.hist.Add(ln.buf.toString())
-		}
-		isHistoryUsed = true
+			if chosen == nil {
+				err = ln.buf.refresh()
+			} else {
+				ln.buf.saveUndo()
+				err = ln.buf.replace(chosen, ln.buf.promptLen+len(chosen))
+			}
+			if err != nil {
+				return "", err
+			}
 
-		ln.buf.grow(len(anotherLine))
-		ln.buf.size = len(anotherLine) + ln.buf.promptLen
-		copy(ln.buf.data[ln.ps1Len:], anotherLine)
+		// === Deleting
 
-		if err = ln.buf.refresh(); err != nil {
-			return "", err
-		}
-		continue
+		case ActionBackwardDeleteChar:
+			ln.buf.saveUndo()
+			if err = ln.buf.deletePrev(); err != nil {
+				return "", err
+			}
 
-	_leftArrow:
-		if err = ln.buf.backward(); err != nil {
-			return "", err
-		}
-		continue
+		case ActionDeleteChar:
+			ln.buf.saveUndo()
+			if err = ln.buf.delete(); err != nil {
+				return "", err
+			}
 
-	_rightArrow:
-		if err = ln.buf.forward(); err != nil {
-			return "", err
-		}
-		continue
+		case ActionKillLine: // Delete from current to end of line.
+			ln.buf.saveUndo()
+			if err = ln.buf.deleteRight(); err != nil {
+				return "", err
+			}
 
-	_start:
-		if err = ln.buf.start(); err != nil {
-			return "", err
-		}
-		continue
+		case ActionKillWholeLine:
+			ln.buf.saveUndo()
+			if err = ln.buf.deleteLine(); err != nil {
+				return "", err
+			}
+			if err = ln.prompt(); err != nil {
+				return "", err
+			}
 
-	_end:
-		if _, err = ln.buf.end(); err != nil {
-			return "", err
+		// Swap actual character by the previous one.
+		case ActionTransposeChars:
+			ln.buf.saveUndo()
+			if err = ln.buf.swap(); err != nil {
+				return "", err
+			}
 		}
-		continue
 	}
 	return
 }
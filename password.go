@@ -0,0 +1,201 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Values by default
+var (
+	QuestionPasswordMask = '*'               // Character echoed for each one typed
+	QuestionRepeatPrompt = "Repeat password" // Question to confirm the password
+)
+
+// Prints the prompt waiting to get a password, without echo. The password is
+// not added to any history, and it should be zeroed after of using it.
+func (q *Question) ReadPassword(prompt string) (password []byte, err error) {
+	return q._baseReadPassword(prompt, false, false)
+}
+
+// Prints the prompt waiting to get a password, echoing QuestionPasswordMask
+// for each character.
+func (q *Question) ReadPasswordMask(prompt string) (password []byte, err error) {
+	return q._baseReadPassword(prompt, true, false)
+}
+
+// Prints the prompt waiting to get a password, which is asked again to confirm
+// it. If both do not match, it is asked from the start. If 'mask' is true,
+// it is echoed QuestionPasswordMask for each character.
+func (q *Question) ReadPasswordConfirm(prompt string, mask bool) (password []byte, err error) {
+	return q._baseReadPassword(prompt, mask, true)
+}
+
+// Base to read passwords. They are never recorded.
+func (q *Question) _baseReadPassword(prompt string, mask, confirm bool) (password []byte, err error) {
+	if q.Answers != nil {
+		key := q.answerKey(prompt)
+
+		answer, ok := q.Answers.Answer(key)
+		if !ok {
+			return nil, fmt.Errorf("question %q: no answer", key)
+		}
+		return []byte(answer), nil
+	}
+
+	if err = tty.RawMode(); err != nil {
+		return nil, err
+	}
+
+	if keyIn == nil {
+		keyIn = newKeyReader(input)
+	}
+
+	for {
+		if password, err = q.askSecret(prompt, mask); err != nil {
+			return nil, err
+		}
+		if !confirm {
+			return password, nil
+		}
+
+		repeated, err := q.askSecret(QuestionRepeatPrompt, mask)
+		if err != nil {
+			zero(password)
+			return nil, err
+		}
+
+		match := bytes.Equal(password, repeated)
+		zero(repeated)
+		if match {
+			return password, nil
+		}
+
+		zero(password)
+		fmt.Fprintf(output, "%sthe passwords do not match\r\n", QuestionErrPrefix)
+	}
+}
+
+// Prints the prompt and reads a password.
+func (q *Question) askSecret(prompt string, mask bool) ([]byte, error) {
+	prompt, _ = q.prompt(prompt, "", _DEFAULT_NO)
+
+	if _, err := output.Write(delLine_CR); err != nil {
+		return nil, outputError(err.Error())
+	}
+	if _, err := fmt.Fprint(output, prompt); err != nil {
+		return nil, outputError(err.Error())
+	}
+
+	return readSecret(keyIn, mask)
+}
+
+// Reads a secret until Enter, from the keys of 'kr'. It is supported
+// Backspace, Ctrl-U to delete all, Ctrl-C to interrupt, and Ctrl-D at the
+// start. The characters typed are not echoed, or they are echoed as
+// QuestionPasswordMask if 'mask' is true.
+//
+// The characters are zeroed after of using them.
+func readSecret(kr *keyReader, mask bool) (secret []byte, err error) {
+	runes := make([]rune, 0, 64)
+	defer func() { zeroRunes(runes[:cap(runes)]) }()
+
+	maskLen := utf8.RuneLen(QuestionPasswordMask)
+
+	for {
+		key, err := kr.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case key.Code == KeyEnter, key == CtrlKey('j'):
+			if _, err = output.Write(_CR_LF); err != nil {
+				return nil, outputError(err.Error())
+			}
+
+			secret = make([]byte, 0, len(runes)*utf8.UTFMax)
+			for _, r := range runes {
+				var b [utf8.UTFMax]byte
+				secret = append(secret, b[:utf8.EncodeRune(b[:], r)]...)
+			}
+			return secret, nil
+
+		case key == CtrlKey('c'):
+			fmt.Fprint(output, CtrlCString, string(_CR_LF))
+			return nil, ErrInterrupted
+
+		case key == CtrlKey('d'):
+			if len(runes) == 0 {
+				fmt.Fprint(output, CtrlDString, string(_CR_LF))
+				return nil, ErrCtrlD
+			}
+
+		case key.Code == KeyBackspace, key == CtrlKey('h'):
+			if len(runes) == 0 {
+				continue
+			}
+			runes[len(runes)-1] = 0
+			runes = runes[:len(runes)-1]
+
+			if mask {
+				if _, err = output.Write(delBackspace); err != nil {
+					return nil, outputError(err.Error())
+				}
+			}
+
+		case key == CtrlKey('u'):
+			if mask {
+				for i := 0; i < len(runes); i++ {
+					if _, err = output.Write(delBackspace); err != nil {
+						return nil, outputError(err.Error())
+					}
+				}
+			}
+			zeroRunes(runes)
+			runes = runes[:0]
+
+		case key.isPrint():
+			// Grow the buffer without leaving copies of the characters.
+			if len(runes) == cap(runes) {
+				bigger := make([]rune, len(runes), 2*cap(runes))
+				copy(bigger, runes)
+				zeroRunes(runes)
+				runes = bigger
+			}
+			runes = append(runes, key.Rune)
+
+			if mask {
+				var b [utf8.UTFMax]byte
+				utf8.EncodeRune(b[:], QuestionPasswordMask)
+				if _, err = output.Write(b[:maskLen]); err != nil {
+					return nil, outputError(err.Error())
+				}
+			}
+		}
+	}
+}
+
+// === Utility
+// ===
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func zeroRunes(r []rune) {
+	for i := range r {
+		r[i] = 0
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadSecret(t *testing.T) {
+	discardOutput(t)
+
+	for _, tt := range []struct {
+		in, want string
+		err      error
+	}{
+		{"secret\r", "secret", nil},
+		{"seccc\x7f\x7fret\r", "secret", nil},
+		{"wrong\x15pássword\r", "pássword", nil},
+		{"abc\x03", "", ErrInterrupted},
+		{"\x04", "", ErrCtrlD},
+	} {
+		for _, mask := range []bool{false, true} {
+			secret, err := readSecret(newKeyReader(strings.NewReader(tt.in)), mask)
+			if err != tt.err || string(secret) != tt.want {
+				t.Errorf("%q (mask %v): got %q, %v", tt.in, mask, secret, err)
+			}
+		}
+	}
+}
@@ -11,6 +11,7 @@ package linoise
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -29,12 +30,6 @@ var (
 // To pass strings in another languages.
 var ExtraBoolString = make(map[string]bool)
 
-// ANSI codes to set graphic mode
-const (
-	setOff  = "\033[0m" // All attributes off
-	setBold = "\033[1m" // Bold on
-)
-
 // Represents if a question has some answer by default.
 type hasDefault int
 
@@ -49,6 +44,17 @@ const (
 
 type Question struct {
 	trueString, falseString string // Strings that represent booleans.
+
+	// If it is set, the answers are got from it instead of the terminal.
+	// When there is no answer, it is used the answer by default, if any.
+	Answers AnswerSource
+
+	// If it is set, the answers given at the terminal are written to it as
+	// lines "key=value", which can be read by LoadAnswers.
+	Record io.Writer
+
+	back bool   // The answer QuestionBackString returns errGoBack
+	key  string // Key of the answer of the next question, instead of the prompt
 }
 
 // Gets a question type.
@@ -66,8 +72,8 @@ func NewQuestion() *Question {
 	}
 
 	return &Question{
-		strings.ToLower(QuestionTrueString),
-		strings.ToLower(QuestionFalseString),
+		trueString:  strings.ToLower(QuestionTrueString),
+		falseString: strings.ToLower(QuestionFalseString),
 	}
 }
 
@@ -80,6 +86,12 @@ func (q *Question) RestoreTerm() {
 
 // Gets a line type ready to show questions.
 func (q *Question) getLine(prompt, defaultAnswer string, def hasDefault) *Line {
+	prompt, ansiLen := q.prompt(prompt, defaultAnswer, def)
+	return NewLinePrompt(prompt, ansiLen, nil) // No history.
+}
+
+// Returns the prompt of a question, and the length of its ANSI codes.
+func (q *Question) prompt(prompt, defaultAnswer string, def hasDefault) (string, int) {
 	var ansiLen int
 
 	// The values by default are set to bold.
@@ -103,12 +115,13 @@ func (q *Question) getLine(prompt, defaultAnswer string, def hasDefault) *Line {
 		prompt += ": "
 	}
 
-	return NewLinePrompt(prompt, ansiLen, nil) // No history.
+	return prompt, ansiLen
 }
 
 // Prints the prompt waiting to get a string not empty.
 func (q *Question) Read(prompt string) (answer string, err error) {
-	line := q.getLine(prompt, "", _DEFAULT_NO)
+	line := q.input(prompt, "", _DEFAULT_NO)
+	defer line.record(&err)
 
 	for {
 		answer, err = line.Read()
@@ -118,128 +131,69 @@ func (q *Question) Read(prompt string) (answer string, err error) {
 		if answer != "" {
 			return
 		}
-	}
-	return
-}
-
-// Base to read strings.
-func (q *Question) _baseReadString(prompt, defaultAnswer string, def hasDefault) (answer string, err error) {
-	line := q.getLine(prompt, defaultAnswer, def)
-
-	for {
-		answer, err = line.Read()
-		if err != nil {
-			return "", err
-		}
-		if answer != "" {
-			// === Check if it is a number
-			if _, err := strconv.Atoi(answer); err == nil {
-				goto _error
-			}
-			if _, err := strconv.ParseFloat(answer, 64); err == nil {
-				goto _error
-			}
-
-			return answer, nil
-		}
-
-		if def != _DEFAULT_NO {
-			return defaultAnswer, nil
-		}
-		continue
-
-	_error:
-		fmt.Fprintf(output, "%s%v: the value has to be a string\r\n",
-			QuestionErrPrefix, answer)
+		line.reject(errEmptyAnswer)
 	}
 	return
 }
 
 // Prints the prompt waiting to get a string.
 func (q *Question) ReadString(prompt string) (answer string, err error) {
-	return q._baseReadString(prompt, "", _DEFAULT_NO)
+	return Ask(q, prompt, ParseString)
 }
 
 // Prints the prompt waiting to get a string.
 // If input is nil then it returns the answer by default.
 func (q *Question) ReadStringDefault(prompt, defaultAnswer string) (answer string, err error) {
-	return q._baseReadString(prompt, defaultAnswer, _DEFAULT_SIMPLE)
+	return AskDefault(q, prompt, ParseString, nil, defaultAnswer)
 }
 
-// Base to read integer numbers.
-func (q *Question) _baseReadInt(prompt string, defaultAnswer int, def hasDefault) (answer int, err error) {
-	line := q.getLine(prompt, strconv.Itoa(defaultAnswer), def)
-
-	for {
-		input, err := line.Read()
-		if err != nil {
-			return 0, err
-		}
-		if input == "" && def != _DEFAULT_NO {
-			return defaultAnswer, nil
-		}
+// Prints the prompt waiting to get a string that passes the validators.
+// Else, it is printed the message of the first one that fails, and it is asked
+// again.
+func (q *Question) ReadStringValid(prompt string, valid ...StringValidator) (answer string, err error) {
+	return Ask(q, prompt, ParseString, valid...)
+}
 
-		answer, err = strconv.Atoi(input)
-		if err != nil {
-			fmt.Fprintf(output, "%s%q: the value has to be an integer\r\n",
-				QuestionErrPrefix, input)
-			continue
-		} else {
-			return answer, nil
-		}
-	}
-	return
+// Prints the prompt waiting to get a string that passes the validators.
+// If input is nil then it returns the answer by default, which is not checked.
+func (q *Question) ReadStringDefaultValid(prompt, defaultAnswer string, valid ...StringValidator) (answer string, err error) {
+	return AskDefault(q, prompt, ParseString, nil, defaultAnswer, valid...)
 }
 
 // Prints the prompt waiting to get an integer number.
 func (q *Question) ReadInt(prompt string) (answer int, err error) {
-	return q._baseReadInt(prompt, 0, _DEFAULT_NO)
+	return Ask(q, prompt, ParseInt[int])
 }
 
 // Prints the prompt waiting to get an integer number.
 // If input is nil then it returns the answer by default.
 func (q *Question) ReadIntDefault(prompt string, defaultAnswer int) (answer int, err error) {
-	return q._baseReadInt(prompt, defaultAnswer, _DEFAULT_SIMPLE)
+	return AskDefault(q, prompt, ParseInt[int], nil, defaultAnswer)
 }
 
-// Base to read float numbers.
-func (q *Question) _baseReadFloat(prompt string, defaultAnswer float64, def hasDefault) (answer float64, err error) {
-	line := q.getLine(
-		prompt,
-		strconv.FormatFloat(defaultAnswer, QuestionFloatFmt, QuestionFloatPrec, 64),
-		def,
-	)
-
-	for {
-		input, err := line.Read()
-		if err != nil {
-			return 0.0, err
-		}
-		if input == "" && def != _DEFAULT_NO {
-			return defaultAnswer, nil
-		}
+// Prints the prompt waiting to get an integer number that passes the
+// validators. Else, it is printed the message of the first one that fails, and
+// it is asked again.
+func (q *Question) ReadIntValid(prompt string, valid ...IntValidator) (answer int, err error) {
+	return Ask(q, prompt, ParseInt[int], valid...)
+}
 
-		answer, err = strconv.ParseFloat(input, 64)
-		if err != nil {
-			fmt.Fprintf(output, "%s%q: the value has to be a float\r\n",
-				QuestionErrPrefix, input)
-			continue
-		} else {
-			return answer, nil
-		}
-	}
-	return
+// Prints the prompt waiting to get an integer number that passes the
+// validators. If input is nil then it returns the answer by default, which is
+// not checked.
+func (q *Question) ReadIntDefaultValid(prompt string, defaultAnswer int, valid ...IntValidator) (answer int, err error) {
+	return AskDefault(q, prompt, ParseInt[int], nil, defaultAnswer, valid...)
 }
 
 // Prints the prompt waiting to get a float number.
 func (q *Question) ReadFloat(prompt string) (answer float64, err error) {
-	return q._baseReadFloat(prompt, 0.0, _DEFAULT_NO)
+	return Ask(q, prompt, ParseFloat)
 }
 
 // Prints the prompt waiting to get a float number.
 // If input is nil then it returns the answer by default.
 func (q *Question) ReadFloatDefault(prompt string, defaultAnswer float64) (answer float64, err error) {
-	return q._baseReadFloat(prompt, defaultAnswer, _DEFAULT_SIMPLE)
+	return AskDefault(q, prompt, ParseFloat, formatFloat, defaultAnswer)
 }
 
 // Prints the prompt waiting to get a string that represents a boolean.
@@ -254,21 +208,24 @@ func (q *Question) ReadBool(prompt string, defaultAnswer bool) (answer bool, err
 			setBold, q.falseString, setOff)
 	}
 
-	line := q.getLine(prompt, options, _DEFAULT_MULTIPLE)
+	line := q.input(prompt, options, _DEFAULT_MULTIPLE)
+	defer line.record(&err)
 
 	for {
 		input, err := line.Read()
 		if err != nil {
 			return false, err
 		}
+		if q.isBack(input) {
+			return false, errGoBack
+		}
 		if input == "" {
 			return defaultAnswer, nil
 		}
 
 		answer, err = atob(input)
 		if err != nil {
-			fmt.Fprintf(output, "%s%s: the value does not represent a boolean\r\n",
-				QuestionErrPrefix, input)
+			line.invalid(fmt.Errorf("the value does not represent a boolean"))
 			continue
 		} else {
 			return answer, nil
@@ -279,19 +236,25 @@ func (q *Question) ReadBool(prompt string, defaultAnswer bool) (answer bool, err
 
 // Base to read strings from a set.
 func (q *Question) _baseReadChoice(prompt string, a []string, defaultAnswer uint) (answer string, err error) {
-	// Saves the value without ANSI to get it when it's set the answer by default.
-	def := a[defaultAnswer]
-	a[defaultAnswer] = setBold + def + setOff
+	// The options shown, with the answer by default in bold. The array is not
+	// modified so the answer is compared against the values without ANSI.
+	shown := make([]string, len(a))
+	copy(shown, a)
+	shown[defaultAnswer] = setBold + a[defaultAnswer] + setOff
 
-	line := q.getLine(prompt, strings.Join(a, ","), _DEFAULT_MULTIPLE)
+	line := q.input(prompt, strings.Join(shown, ","), _DEFAULT_MULTIPLE)
+	defer line.record(&err)
 
 	for {
 		answer, err = line.Read()
 		if err != nil {
 			return "", err
 		}
+		if q.isBack(answer) {
+			return "", errGoBack
+		}
 		if answer == "" {
-			return def, nil
+			return a[defaultAnswer], nil
 		}
 
 		for _, v := range a {
@@ -299,6 +262,7 @@ func (q *Question) _baseReadChoice(prompt string, a []string, defaultAnswer uint
 				return answer, nil
 			}
 		}
+		line.reject(fmt.Errorf("the value is not in %q", a))
 	}
 	return
 }
@@ -323,6 +287,12 @@ func (q *Question) ReadChoiceDefault(prompt string, a []string, defaultAnswer ui
 // === Utility
 // ===
 
+// Reports whether the answer is to go back to the previous question, which is
+// only done at the terminal.
+func (q *Question) isBack(answer string) bool {
+	return q.back && q.Answers == nil && answer == QuestionBackString
+}
+
 // Returns the boolean value represented by the string.
 // It accepts "y, Y, yes, YES, Yes, n, N, no, NO, No". And values in
 // 'strconv.Atob', and 'ExtraBoolString'. Any other value returns an error.
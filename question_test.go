@@ -11,6 +11,7 @@ package linoise
 
 import (
 	"fmt"
+	"os"
 	"testing"
 )
 
@@ -60,3 +61,19 @@ func print(a interface{}, err error) {
 		fmt.Printf(err.Error() + "\r\n")
 	}
 }
+
+// Redirects the output to /dev/null until the end of the test.
+func discardOutput(t *testing.T) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := output
+	output = null
+
+	t.Cleanup(func() {
+		output = stdout
+		null.Close()
+	})
+}
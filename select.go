@@ -0,0 +1,442 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values by default
+var (
+	SelectLines  = 10   // Options shown at once
+	SelectCursor = "> " // String placed before of the option under the cursor
+)
+
+// Shows the options in a list below of the prompt, waiting to choose one with
+// the arrows (or 'j' and 'k') and Enter. Typing filters the options, so 'j'
+// and 'k' are only used to move when there is no filter.
+// Returns the position of the option chosen and its value.
+func (q *Question) Select(prompt string, options []string) (index int, value string, err error) {
+	return q._baseSelect(prompt, options, 0, false)
+}
+
+// Shows the options in a list, with the cursor at the option by default.
+func (q *Question) SelectDefault(prompt string, options []string, defaultAnswer int) (index int, value string, err error) {
+	if defaultAnswer < 0 || defaultAnswer >= len(options) {
+		panic(fmt.Sprintf("SelectDefault: element %d is not in array",
+			defaultAnswer))
+	}
+	return q._baseSelect(prompt, options, defaultAnswer, true)
+}
+
+// Base to select options.
+func (q *Question) _baseSelect(prompt string, options []string, defaultAnswer int, hasDef bool) (index int, value string, err error) {
+	if len(options) == 0 {
+		panic("Select: there are no options")
+	}
+	key := q.answerKey(prompt)
+
+	if q.Answers != nil {
+		if index, err = q.answerSelect(key, options, defaultAnswer, hasDef); err != nil {
+			return -1, "", err
+		}
+		return index, options[index], nil
+	}
+
+	prompt, _ = q.prompt(prompt, "", _DEFAULT_NO)
+
+	m := newMenu(prompt, options)
+	m.cursor, m.top = scroll(defaultAnswer, 0, len(m.shown), m.lines)
+	if hasDef {
+		m.defaults[defaultAnswer] = true
+	}
+
+	if index, err = m.run(); err != nil {
+		return -1, "", err
+	}
+	q.recordAnswer(key, options[index])
+	return index, options[index], nil
+}
+
+// Shows the options in a list with checkboxes, which are toggled with Space.
+// The right arrow checks all options shown, and the left one unchecks them.
+// The options at positions 'checked' are checked at the start.
+//
+// There have to be 'min' options checked at least, and 'max' at most, if it is
+// not 0. Returns the positions of the options checked.
+func (q *Question) MultiSelect(prompt string, options []string, checked []int, min, max int) (selected []int, err error) {
+	if len(options) == 0 {
+		panic("MultiSelect: there are no options")
+	}
+	for _, i := range checked {
+		if i < 0 || i >= len(options) {
+			panic(fmt.Sprintf("MultiSelect: element %d is not in array", i))
+		}
+	}
+	if min < 0 || min > len(options) {
+		panic(fmt.Sprintf("MultiSelect: %d options can not be checked", min))
+	}
+	if max < 0 || max != 0 && max < min {
+		panic(fmt.Sprintf("MultiSelect: wrong maximum %d", max))
+	}
+	if max != 0 && len(checked) > max {
+		panic(fmt.Sprintf("MultiSelect: %d options checked, more than %d",
+			len(checked), max))
+	}
+	key := q.answerKey(prompt)
+
+	if q.Answers != nil {
+		return q.answerMultiSelect(key, options, checked, min, max)
+	}
+
+	prompt, _ = q.prompt(prompt, "", _DEFAULT_NO)
+
+	m := newMenu(prompt, options)
+	m.marked = make([]bool, len(options))
+	m.min, m.max = min, max
+
+	for _, i := range checked {
+		m.marked[i] = true
+		m.defaults[i] = true
+	}
+
+	if _, err = m.run(); err != nil {
+		return nil, err
+	}
+
+	selected = m.checked()
+	values := make([]string, len(selected))
+	for i, j := range selected {
+		values[i] = options[j]
+	}
+	q.recordAnswer(key, joinValues(values))
+	return selected, nil
+}
+
+// === Menu
+// ===
+
+// menu is a list of options shown below of the prompt, which can be filtered.
+type menu struct {
+	prompt  string
+	options []string
+	lines   int // Options shown at once
+	columns int
+
+	filter []rune
+	shown  []int   // Positions of the options matched by the filter
+	match  [][]int // Characters matched in each option shown
+	cursor int     // Position in 'shown'
+	top    int     // First option shown
+
+	// === Checkboxes, when 'marked' is not nil
+	marked   []bool
+	min, max int    // Options to check; 'max' is not checked if it is 0
+	msg      string // Error shown after of the prompt
+
+	defaults []bool // Options by default, which are shown in bold
+}
+
+func newMenu(prompt string, options []string) *menu {
+	m := &menu{
+		prompt:  prompt,
+		options: options,
+		lines:   SelectLines,
+		columns: 80,
+
+		defaults: make([]bool, len(options)),
+	}
+	if len(options) < m.lines {
+		m.lines = len(options)
+	}
+
+	m.filterOptions()
+	return m
+}
+
+// Gets the options matched by the filter, keeping the cursor at the same option
+// if it is shown yet.
+func (m *menu) filterOptions() {
+	current := -1
+	if m.cursor < len(m.shown) {
+		current = m.shown[m.cursor]
+	}
+
+	m.shown, m.match = m.shown[:0], m.match[:0]
+	m.cursor = 0
+
+	for i, option := range m.options {
+		if _, pos, ok := fuzzyMatch(string(m.filter), option); ok {
+			if i == current {
+				m.cursor = len(m.shown)
+			}
+			m.shown = append(m.shown, i)
+			m.match = append(m.match, pos)
+		}
+	}
+	m.move(0)
+}
+
+// Moves the cursor 'n' options.
+func (m *menu) move(n int) {
+	m.cursor, m.top = scroll(m.cursor+n, m.top, len(m.shown), m.lines)
+}
+
+// Returns the positions of the options checked.
+func (m *menu) checked() []int {
+	checked := make([]int, 0)
+	for i, marked := range m.marked {
+		if marked {
+			checked = append(checked, i)
+		}
+	}
+	return checked
+}
+
+// Checks or unchecks the options shown at the positions given, if it is not
+// exceeded the maximum.
+func (m *menu) mark(check bool, shown ...int) {
+	if check && m.max != 0 {
+		n := len(m.checked())
+		for _, i := range shown {
+			if !m.marked[m.shown[i]] {
+				n++
+			}
+		}
+		if n > m.max {
+			m.msg = fmt.Sprintf("%d options can be checked at most", m.max)
+			return
+		}
+	}
+
+	for _, i := range shown {
+		m.marked[m.shown[i]] = check
+	}
+}
+
+// Handles a key. Returns true when an option is chosen, or the options are
+// checked.
+func (m *menu) key(k Key) (done bool, err error) {
+	m.msg = ""
+
+	if m.marked != nil {
+		all := make([]int, len(m.shown))
+		for i := range all {
+			all[i] = i
+		}
+
+		switch {
+		case k.Code == KeyEnter, k == CtrlKey('j'):
+			if n := len(m.checked()); n < m.min {
+				m.msg = fmt.Sprintf("%d options have to be checked at least", m.min)
+				return false, nil
+			}
+			return true, nil
+
+		case k == Key{Rune: ' '}:
+			if len(m.shown) != 0 {
+				m.mark(!m.marked[m.shown[m.cursor]], m.cursor)
+			}
+			return false, nil
+		case k.Code == KeyRight:
+			m.mark(true, all...)
+			return false, nil
+		case k.Code == KeyLeft:
+			m.mark(false, all...)
+			return false, nil
+		}
+	}
+
+	switch {
+	case k.Code == KeyEnter, k == CtrlKey('j'):
+		return len(m.shown) != 0, nil
+
+	case k == CtrlKey('c'):
+		return false, ErrInterrupted
+
+	case k.Code == KeyUp, k == CtrlKey('p'), k == Key{Rune: 'k'} && len(m.filter) == 0:
+		m.move(-1)
+	case k.Code == KeyDown, k == CtrlKey('n'), k == Key{Rune: 'j'} && len(m.filter) == 0:
+		m.move(1)
+	case k.Code == KeyPageUp:
+		m.move(-m.lines)
+	case k.Code == KeyPageDown:
+		m.move(m.lines)
+	case k.Code == KeyHome:
+		m.move(-len(m.shown))
+	case k.Code == KeyEnd:
+		m.move(len(m.shown))
+
+	case k.Code == KeyBackspace, k == CtrlKey('h'):
+		if len(m.filter) != 0 {
+			m.filter = m.filter[:len(m.filter)-1]
+			m.filterOptions()
+		}
+	case k == CtrlKey('u'), k.Code == KeyEscape:
+		m.filter = m.filter[:0]
+		m.filterOptions()
+
+	case k.isPrint():
+		m.filter = append(m.filter, k.Rune)
+		m.filterOptions()
+	}
+	return false, nil
+}
+
+// Shows the menu until an option is chosen, returning its position.
+func (m *menu) run() (index int, err error) {
+	if err = tty.RawMode(); err != nil {
+		return -1, err
+	}
+	if _, columns, err := tty.GetSize(); err == nil {
+		m.columns = columns
+	}
+
+	if keyIn == nil {
+		keyIn = newKeyReader(input)
+	}
+
+	for {
+		if err = m.draw(); err != nil {
+			return -1, err
+		}
+
+		k, err := keyIn.readKey()
+		if err != nil {
+			return -1, err
+		}
+
+		done, err := m.key(k)
+		if err != nil {
+			m.clear()
+			fmt.Fprint(output, CtrlCString, string(_CR_LF))
+			return -1, err
+		}
+		if done {
+			if m.marked == nil {
+				index = m.shown[m.cursor]
+			}
+			if err = m.clear(); err != nil {
+				return -1, err
+			}
+
+			// The answer is written after of the prompt.
+			if _, err = fmt.Fprint(output, m.answer(index), string(_CR_LF)); err != nil {
+				return -1, outputError(err.Error())
+			}
+			return index, nil
+		}
+	}
+}
+
+// === Output
+// ===
+
+// Returns the answer written after of the prompt: the option chosen, or the
+// ones checked.
+func (m *menu) answer(index int) string {
+	if m.marked == nil {
+		return m.options[index]
+	}
+
+	values := make([]string, 0)
+	for _, i := range m.checked() {
+		values = append(values, m.options[i])
+	}
+	return strings.Join(values, ", ")
+}
+
+// Writes the prompt with the filter, and the options, from the start of the
+// line of the prompt, where the cursor is left.
+func (m *menu) draw() error {
+	var b bytes.Buffer
+
+	b.WriteString("\r")
+	b.Write(delRight)
+	b.WriteString(m.prompt)
+	b.WriteString(string(m.filter))
+	if m.msg != "" {
+		b.WriteString(QuestionErrPrefix)
+		b.WriteString(setBold + m.msg + setOff)
+	}
+
+	indent := utf8.RuneCountInString(SelectCursor)
+	box := 0 // Width of the checkbox
+	if m.marked != nil {
+		box = len("[x] ")
+	}
+
+	for i := 0; i < m.lines; i++ {
+		b.Write(_CR_LF)
+		b.Write(delRight)
+
+		j := m.top + i
+		if j >= len(m.shown) {
+			continue
+		}
+
+		attr := setOff
+		if j == m.cursor {
+			attr += string(setReverse)
+			b.WriteString(SelectCursor)
+		} else {
+			b.WriteString(fmt.Sprintf("%*s", indent, ""))
+		}
+		if m.defaults[m.shown[j]] {
+			attr += setBold
+		}
+		b.WriteString(attr)
+
+		if m.marked != nil {
+			if m.marked[m.shown[j]] {
+				b.WriteString("[x] ")
+			} else {
+				b.WriteString("[ ] ")
+			}
+		}
+
+		writeMatched(&b, []rune(m.options[m.shown[j]]), m.match[j], m.columns-indent-box-1, attr)
+		b.WriteString(setOff)
+	}
+
+	for i := 0; i < m.lines; i++ {
+		b.Write(cursorUp)
+	}
+	b.Write(toColumn(utf8.RuneCountInString(m.prompt) + len(m.filter)))
+
+	if _, err := output.Write(b.Bytes()); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
+
+// Erases the filter and the options, leaving the cursor after of the prompt.
+func (m *menu) clear() error {
+	var b bytes.Buffer
+
+	b.WriteString("\r")
+	b.Write(delRight)
+	for i := 0; i < m.lines; i++ {
+		b.Write(cursorDown)
+		b.Write(delRight)
+	}
+	for i := 0; i < m.lines; i++ {
+		b.Write(cursorUp)
+	}
+	b.WriteString(m.prompt)
+
+	if _, err := output.Write(b.Bytes()); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMenu(t *testing.T) {
+	options := []string{"apple", "banana", "cherry", "date", "kiwi", "jackfruit"}
+	m := newMenu("fruit: ", options)
+	m.lines = 3
+
+	keys := func(ks ...Key) (done bool) {
+		for _, k := range ks {
+			var err error
+			if done, err = m.key(k); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	chosen := func() string { return options[m.shown[m.cursor]] }
+
+	keys(Key{Rune: 'j'}, Key{Code: KeyDown}, Key{Rune: 'j'})
+	if chosen() != "date" || m.top != 1 {
+		t.Errorf("moving down: at %q, top %d", chosen(), m.top)
+	}
+	keys(Key{Code: KeyUp}, Key{Rune: 'k'})
+	if chosen() != "banana" {
+		t.Errorf("moving up: at %q", chosen())
+	}
+	keys(Key{Code: KeyEnd})
+	if chosen() != "jackfruit" || m.top != 3 {
+		t.Errorf("end: at %q, top %d", chosen(), m.top)
+	}
+
+	// === Filter
+	keys(Key{Rune: 'a'}, Key{Rune: 'k'})
+	if len(m.shown) != 1 || chosen() != "jackfruit" {
+		t.Errorf("filter %q: shown %v", string(m.filter), m.shown)
+	}
+	keys(Key{Code: KeyBackspace}, Key{Rune: 'n'})
+	if len(m.shown) != 1 || chosen() != "banana" {
+		t.Errorf("filter %q: shown %v", string(m.filter), m.shown)
+	}
+
+	keys(Key{Rune: 'x'})
+	if len(m.shown) != 0 || keys(Key{Code: KeyEnter}) {
+		t.Error("chosen without options shown")
+	}
+
+	keys(CtrlKey('u'))
+	if len(m.shown) != len(options) {
+		t.Errorf("filter removed: shown %v", m.shown)
+	}
+	if !keys(Key{Code: KeyEnter}) {
+		t.Error("Enter: expected option chosen")
+	}
+
+	if _, err := m.key(CtrlKey('c')); err != ErrInterrupted {
+		t.Errorf("Ctrl-C: got error %v", err)
+	}
+}
+
+func TestMenuMulti(t *testing.T) {
+	m := newMenu("features: ", []string{"docs", "tests", "examples", "debug"})
+	m.marked = make([]bool, 4)
+	m.marked[3] = true
+	m.min, m.max = 1, 3
+
+	key := func(k Key) bool {
+		done, err := m.key(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return done
+	}
+	equal := func(name string, want ...int) {
+		got := m.checked()
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+			return
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: got %v, want %v", name, got, want)
+				return
+			}
+		}
+	}
+
+	key(Key{Rune: ' '})
+	key(Key{Code: KeyDown})
+	key(Key{Rune: ' '})
+	equal("Space", 0, 1, 3)
+
+	key(Key{Code: KeyLeft})
+	equal("none")
+	if key(Key{Code: KeyEnter}) || m.msg == "" {
+		t.Error("accepted less options than the minimum")
+	}
+
+	key(Key{Code: KeyRight})
+	equal("all over the maximum")
+	if m.msg == "" {
+		t.Error("expected message for the maximum")
+	}
+
+	// Only the options shown are checked.
+	key(Key{Rune: 'e'})
+	key(Key{Rune: 's'})
+	key(Key{Code: KeyRight})
+	equal("all shown", 1, 2)
+
+	if !key(Key{Code: KeyEnter}) {
+		t.Error("Enter: expected options accepted")
+	}
+	if s := m.answer(0); s != "tests, examples" {
+		t.Errorf("answer: got %q", s)
+	}
+}
+
+func TestMultiSelectArgs(t *testing.T) {
+	q := NewQuestion()
+	q.Answers = MapAnswers{}
+	options := []string{"docs", "tests", "examples"}
+
+	tests := []struct {
+		checked  []int
+		min, max int
+	}{
+		{nil, 4, 0},         // More than the options
+		{nil, 2, 1},         // Minimum over the maximum
+		{[]int{0, 1}, 0, 1}, // Checked over the maximum
+		{nil, -1, 0},
+	}
+	for i, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d. expected panic", i)
+				}
+			}()
+			q.MultiSelect("Features", options, tt.checked, tt.min, tt.max)
+		}()
+	}
+
+	if sel, err := q.MultiSelect("Features", options, []int{2}, 1, 1); err != nil || len(sel) != 1 || sel[0] != 2 {
+		t.Errorf("valid arguments: got %v, %v", sel, err)
+	}
+}
+
+func TestMenuDraw(t *testing.T) {
+	file, err := ioutil.TempFile("", "menu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stdout := output
+	output = file
+	defer func() { output = stdout }()
+
+	m := newMenu("features: ", []string{"docs", "tests"})
+	m.marked = []bool{false, true}
+	m.defaults[1] = true
+	if err = m.draw(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(file.Name())
+	if !strings.Contains(string(data), setBold+"[x] tests") {
+		t.Errorf("option by default not in bold: %q", data)
+	}
+	if strings.Contains(string(data), setBold+"[ ] docs") {
+		t.Errorf("option not by default in bold: %q", data)
+	}
+}
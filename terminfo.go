@@ -0,0 +1,582 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	errTermNotFound = errors.New("terminfo: terminal not found")
+	errTermFormat   = errors.New("terminfo: wrong format")
+)
+
+// Magic numbers of compiled terminfo files.
+const (
+	_TERMINFO_MAGIC    = 0432  // Numbers of 16 bits
+	_TERMINFO_MAGIC_32 = 01036 // Numbers of 32 bits
+)
+
+// Indexes of string capabilities into compiled terminfo files, in the order
+// given by "term.h".
+const (
+	tiCarriageReturn = 2  // cr
+	tiClrEol         = 6  // el
+	tiCursorDown     = 11 // cud1
+	tiCursorLeft     = 14 // cub1
+	tiCursorRight    = 17 // cuf1
+	tiCursorUp       = 19 // cuu1
+	tiDeleteChar     = 21 // dch1
+	tiBold           = 27 // bold
+	tiReverse        = 34 // rev
+	tiAttributeOff   = 39 // sgr0
+
+	tiKeyDelete   = 59 // kdch1
+	tiKeyDown     = 61 // kcud1
+	tiKeyF1       = 66 // kf1
+	tiKeyF10      = 67 // kf10
+	tiKeyF2       = 68 // kf2 to kf9 are consecutive
+	tiKeyHome     = 76 // khome
+	tiKeyInsert   = 77 // kich1
+	tiKeyLeft     = 79 // kcub1
+	tiKeyPageDown = 81 // knp
+	tiKeyPageUp   = 82 // kpp
+	tiKeyRight    = 83 // kcuf1
+	tiKeyUp       = 87 // kcuu1
+	tiKeyEnd      = 164
+	tiKeyF11      = 216
+	tiKeyF12      = 217
+
+	tiParmRight = 112 // cuf
+)
+
+// === Type
+// ===
+
+// Represents the capabilities of a terminal, got from a compiled terminfo file.
+type terminfo struct {
+	names   []string          // Names of the terminal
+	strings []string          // String capabilities, by index
+	ext     map[string]string // Extended string capabilities, by name
+}
+
+// Gets the capabilities of the terminal with the given name, from the
+// directories used by ncurses.
+func loadTerminfo(name string) (*terminfo, error) {
+	if name == "" {
+		return nil, errTermNotFound
+	}
+
+	var dirs []string
+
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	if list := os.Getenv("TERMINFO_DIRS"); list != "" {
+		for _, dir := range strings.Split(list, ":") {
+			if dir == "" {
+				dir = "/usr/share/terminfo"
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	dirs = append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo")
+
+	for _, dir := range dirs {
+		// The subdirectory is the first character, or its hexadecimal value
+		// in systems with file names case-insensitive.
+		for _, sub := range []string{name[:1], strconv.FormatInt(int64(name[0]), 16)} {
+			data, err := ioutil.ReadFile(filepath.Join(dir, sub, name))
+			if err == nil {
+				return parseTerminfo(data)
+			}
+		}
+	}
+
+	return nil, errTermNotFound
+}
+
+// Parses a compiled terminfo file, as described in term(5).
+func parseTerminfo(data []byte) (*terminfo, error) {
+	rd := &tiReader{data: data}
+
+	// === Header
+	magic := rd.short()
+	nameSize := rd.count()
+	boolCount := rd.count()
+	numCount := rd.count()
+	strCount := rd.count()
+	tableSize := rd.count()
+
+	if rd.err != nil {
+		return nil, rd.err
+	}
+
+	numSize := 2
+	if magic == _TERMINFO_MAGIC_32 {
+		numSize = 4
+	} else if magic != _TERMINFO_MAGIC {
+		return nil, errTermFormat
+	}
+
+	ti := &terminfo{ext: make(map[string]string)}
+
+	names := rd.next(nameSize)
+	ti.names = strings.Split(strings.TrimRight(string(names), "\x00"), "|")
+
+	rd.next(boolCount)
+	rd.align()
+	rd.next(numCount * numSize)
+
+	offsets := make([]int, strCount)
+	for i := range offsets {
+		offsets[i] = rd.short()
+	}
+	table := rd.next(tableSize)
+
+	if rd.err != nil {
+		return nil, rd.err
+	}
+
+	ti.strings = make([]string, strCount)
+	for i, off := range offsets {
+		ti.strings[i] = cString(table, off)
+	}
+
+	// === Extended capabilities, added by ncurses after of the string table.
+	rd.align()
+	if rd.pos >= len(data) {
+		return ti, nil
+	}
+
+	extBools := rd.count()
+	extNums := rd.count()
+	extStrs := rd.count()
+	rd.count() // Number of items in the table
+	extTableSize := rd.count()
+
+	if rd.err != nil {
+		return nil, rd.err
+	}
+
+	rd.next(extBools)
+	rd.align()
+	rd.next(extNums * numSize)
+
+	extOffsets := make([]int, extStrs)
+	for i := range extOffsets {
+		extOffsets[i] = rd.short()
+	}
+	nameOffsets := make([]int, extBools+extNums+extStrs)
+	for i := range nameOffsets {
+		nameOffsets[i] = rd.short()
+	}
+	extTable := rd.next(extTableSize)
+
+	if rd.err != nil {
+		return nil, rd.err
+	}
+
+	// The names are placed after of the last string value.
+	var namesStart int
+	for _, off := range extOffsets {
+		if off >= 0 && off < len(extTable) {
+			if end := off + bytes.IndexByte(extTable[off:], 0) + 1; end > namesStart {
+				namesStart = end
+			}
+		}
+	}
+	if namesStart > len(extTable) {
+		return nil, errTermFormat
+	}
+
+	for i, off := range extOffsets {
+		name := cString(extTable[namesStart:], nameOffsets[extBools+extNums+i])
+		if name != "" {
+			ti.ext[name] = cString(extTable, off)
+		}
+	}
+
+	return ti, nil
+}
+
+// Returns the string capability with the given index, or an empty string if
+// the terminal has not it.
+func (ti *terminfo) str(i int) string {
+	if i < len(ti.strings) {
+		return stripDelay(ti.strings[i])
+	}
+	return ""
+}
+
+// === Reading of binary data
+// ===
+
+// Reads values of a compiled terminfo file.
+type tiReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// Returns the next short integer (little endian), or -1 for values that are
+// absent or cancelled.
+func (rd *tiReader) short() int {
+	b := rd.next(2)
+	if b == nil {
+		return 0
+	}
+
+	n := int16(binary.LittleEndian.Uint16(b))
+	if n < 0 {
+		return -1
+	}
+	return int(n)
+}
+
+// Returns the next short integer as a number of items or bytes, which can not
+// be absent.
+func (rd *tiReader) count() int {
+	n := rd.short()
+	if n < 0 && rd.err == nil {
+		rd.err = errTermFormat
+		return 0
+	}
+	return n
+}
+
+// Returns the next n bytes.
+func (rd *tiReader) next(n int) []byte {
+	if rd.err != nil {
+		return nil
+	}
+	if n < 0 || rd.pos+n > len(rd.data) {
+		rd.err = errTermFormat
+		return nil
+	}
+
+	b := rd.data[rd.pos : rd.pos+n]
+	rd.pos += n
+	return b
+}
+
+// Skips the null byte used to align sections to an even offset.
+func (rd *tiReader) align() {
+	if rd.pos%2 != 0 {
+		rd.pos++
+	}
+}
+
+// Returns the string ended in null which starts at the offset.
+func cString(table []byte, off int) string {
+	if off < 0 || off >= len(table) {
+		return ""
+	}
+
+	end := bytes.IndexByte(table[off:], 0)
+	if end == -1 {
+		return ""
+	}
+	return string(table[off : off+end])
+}
+
+// Removes the padding delays, as "$<5>".
+func stripDelay(s string) string {
+	for {
+		i := strings.Index(s, "$<")
+		if i == -1 {
+			return s
+		}
+		j := strings.IndexByte(s[i:], '>')
+		if j == -1 {
+			return s
+		}
+		s = s[:i] + s[i+j+1:]
+	}
+}
+
+// === Parameters
+// ===
+
+// Instantiates a parameterized string with the given parameters, as the
+// function "tparm" of ncurses. Only numeric parameters are supported.
+func tparm(s string, params ...int) string {
+	var (
+		buf   bytes.Buffer
+		stack []int
+		vars  [26]int
+	)
+	var p [9]int
+	copy(p[:], params)
+
+	push := func(n int) { stack = append(stack, n) }
+	pop := func() int {
+		if len(stack) == 0 {
+			return 0
+		}
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return n
+	}
+	b2i := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+
+		switch c := s[i]; c {
+		case '%':
+			buf.WriteByte('%')
+		case 'c':
+			buf.WriteByte(byte(pop()))
+		case 'p':
+			if i+1 < len(s) && s[i+1] >= '1' && s[i+1] <= '9' {
+				i++
+				push(p[s[i]-'1'])
+			}
+		case 'P', 'g':
+			if i+1 < len(s) && s[i+1] >= 'a' && s[i+1] <= 'z' {
+				i++
+				if c == 'P' {
+					vars[s[i]-'a'] = pop()
+				} else {
+					push(vars[s[i]-'a'])
+				}
+			}
+		case '\'':
+			if i+2 < len(s) {
+				push(int(s[i+1]))
+				i += 2
+			}
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end == -1 {
+				return buf.String()
+			}
+			n, _ := strconv.Atoi(s[i+1 : i+end])
+			push(n)
+			i += end
+		case 'l':
+			push(len(strconv.Itoa(pop())))
+		case 'i':
+			p[0]++
+			p[1]++
+
+		case '+', '-', '*', '/', 'm', '&', '|', '^', '=', '>', '<', 'A', 'O':
+			y, x := pop(), pop()
+
+			switch c {
+			case '+':
+				push(x + y)
+			case '-':
+				push(x - y)
+			case '*':
+				push(x * y)
+			case '/':
+				if y != 0 {
+					push(x / y)
+				} else {
+					push(0)
+				}
+			case 'm':
+				if y != 0 {
+					push(x % y)
+				} else {
+					push(0)
+				}
+			case '&':
+				push(x & y)
+			case '|':
+				push(x | y)
+			case '^':
+				push(x ^ y)
+			case '=':
+				push(b2i(x == y))
+			case '>':
+				push(b2i(x > y))
+			case '<':
+				push(b2i(x < y))
+			case 'A':
+				push(b2i(x != 0 && y != 0))
+			case 'O':
+				push(b2i(x != 0 || y != 0))
+			}
+		case '!':
+			push(b2i(pop() == 0))
+		case '~':
+			push(^pop())
+
+		// === Conditionals: %? expr %t then %e else %;
+		case '?', ';':
+		case 't':
+			if pop() == 0 {
+				i = skipCond(s, i+1, true)
+			}
+		case 'e':
+			i = skipCond(s, i+1, false)
+
+		// === Output, with optional flags as "%:-3d"
+		default:
+			j := i
+			if s[j] == ':' {
+				j++
+			}
+			for j < len(s) && strings.IndexByte("-+# .0123456789", s[j]) != -1 {
+				j++
+			}
+			if j == len(s) || strings.IndexByte("doxXs", s[j]) == -1 {
+				break
+			}
+
+			format := "%" + s[i:j]
+			if strings.HasPrefix(format, "%:") {
+				format = "%" + format[2:]
+			}
+			if s[j] == 's' {
+				fmt.Fprintf(&buf, format+"d", pop())
+			} else {
+				fmt.Fprintf(&buf, format+string(s[j]), pop())
+			}
+			i = j
+		}
+	}
+
+	return buf.String()
+}
+
+// Returns the position of the last character of the "%e" (if 'toElse') or
+// "%;" which closes the conditional at the actual level, starting at 'i'.
+func skipCond(s string, i int, toElse bool) int {
+	level := 0
+
+	for ; i < len(s)-1; i++ {
+		if s[i] != '%' {
+			continue
+		}
+		i++
+
+		switch s[i] {
+		case '?':
+			level++
+		case ';':
+			if level == 0 {
+				return i
+			}
+			level--
+		case 'e':
+			if level == 0 && toElse {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+// === Setting
+// ===
+
+// Sets the control sequences and keys of the terminal, keeping the values by
+// default (ANSI) for the capabilities that it has not.
+func (ti *terminfo) setup() {
+	set := func(dst *[]byte, caps ...string) {
+		for _, c := range caps {
+			if c == "" {
+				return
+			}
+		}
+		*dst = []byte(strings.Join(caps, ""))
+	}
+
+	cr := ti.str(tiCarriageReturn)
+	up, down := ti.str(tiCursorUp), ti.str(tiCursorDown)
+	el := ti.str(tiClrEol)
+
+	set(&cursorUp, up)
+	set(&cursorDown, down)
+	set(&cursorForward, ti.str(tiCursorRight))
+	set(&cursorBackward, ti.str(tiCursorLeft))
+
+	// "\033[E" and "\033[F" are not supported by all terminals.
+	set(&toNextLine, cr, down)
+	set(&toPreviousLine, cr, up)
+
+	set(&delRight, el)
+	set(&delLine_CR, cr, el)
+	set(&delLine_cursorUp, cr, el, up)
+	set(&delChar, ti.str(tiDeleteChar))
+	set(&delBackspace, ti.str(tiCursorLeft), ti.str(tiDeleteChar))
+
+	if s := ti.str(tiParmRight); s != "" {
+		parmForward = s
+	}
+
+	var bold, off []byte
+	set(&bold, ti.str(tiBold))
+	set(&off, ti.str(tiAttributeOff))
+	if bold != nil && off != nil {
+		setBold, setOff = string(bold), string(off)
+	}
+	set(&setReverse, ti.str(tiReverse))
+
+	// Bracketed paste, in the extended capabilities of recent ncurses.
+	if s := ti.ext["BE"]; s != "" {
+		pasteOn = []byte(s)
+	}
+	if s := ti.ext["BD"]; s != "" {
+		pasteOff = []byte(s)
+	}
+
+	// === Keys
+	keys := map[int]KeyCode{
+		tiKeyUp:       KeyUp,
+		tiKeyDown:     KeyDown,
+		tiKeyRight:    KeyRight,
+		tiKeyLeft:     KeyLeft,
+		tiKeyHome:     KeyHome,
+		tiKeyEnd:      KeyEnd,
+		tiKeyInsert:   KeyInsert,
+		tiKeyDelete:   KeyDelete,
+		tiKeyPageUp:   KeyPageUp,
+		tiKeyPageDown: KeyPageDown,
+		tiKeyF1:       KeyF1,
+		tiKeyF10:      KeyF10,
+		tiKeyF11:      KeyF11,
+		tiKeyF12:      KeyF12,
+	}
+	for i := 0; i < 8; i++ {
+		keys[tiKeyF2+i] = KeyF2 + KeyCode(i)
+	}
+
+	for i, code := range keys {
+		// Only the escape sequences are decoded by the terminal capabilities.
+		if s := ti.str(i); strings.HasPrefix(s, "\033[") || strings.HasPrefix(s, "\033O") {
+			seqKeys[s] = Key{Code: code}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"testing"
+)
+
+func TestTerminfo(t *testing.T) {
+	ti, err := loadTerminfo("xterm-256color")
+	if err == errTermNotFound {
+		t.Skip("terminfo for xterm-256color not installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caps := map[int]string{
+		tiCarriageReturn: "\r",
+		tiCursorUp:       "\033[A",
+		tiClrEol:         "\033[K",
+		tiDeleteChar:     "\033[P",
+		tiAttributeOff:   "\033(B\033[m",
+		tiParmRight:      "\033[%p1%dC",
+		tiKeyDelete:      "\033[3~",
+		tiKeyEnd:         "\033OF",
+		tiKeyF2:          "\033OQ",
+		tiKeyF2 + 7:      "\033[20~", // F9
+		tiKeyF11:         "\033[23~",
+		tiKeyF12:         "\033[24~",
+		tiKeyPageDown:    "\033[6~",
+	}
+	for i, want := range caps {
+		if got := ti.str(i); got != want {
+			t.Errorf("capability %d: got %q, want %q", i, got, want)
+		}
+	}
+
+	if got := ti.ext["BE"]; got != "\033[?2004h" {
+		t.Errorf("extended capability BE: got %q", got)
+	}
+}
+
+func TestTerminfoDelay(t *testing.T) {
+	ti, err := loadTerminfo("vt100")
+	if err == errTermNotFound {
+		t.Skip("terminfo for vt100 not installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ti.str(tiClrEol); got != "\033[K" {
+		t.Errorf("padding not removed: %q", got)
+	}
+}
+
+func TestTerminfoMalformed(t *testing.T) {
+	header := func(counts ...uint16) []byte {
+		b := []byte{0x1a, 0x01} // Magic
+		for _, n := range counts {
+			b = append(b, byte(n), byte(n>>8))
+		}
+		return b
+	}
+
+	tests := [][]byte{
+		nil,
+		header(0, 0, 0, 0xFFFF, 0), // Absent count of strings
+		header(2, 0, 0, 1, 0),      // Truncated
+		append(header(0, 0, 0, 0, 0), 0xFF, 0xFF, 0, 0, 0, 0), // Absent extended count
+	}
+	for i, data := range tests {
+		if _, err := parseTerminfo(data); err != errTermFormat {
+			t.Errorf("%d. got error %v", i, err)
+		}
+	}
+}
+
+var tparmTests = []struct {
+	in     string
+	params []int
+	out    string
+}{
+	{"\033[%p1%dC", []int{5}, "\033[5C"},
+	{"\033[%i%p1%d;%p2%dH", []int{2, 9}, "\033[3;10H"},
+	{"\033[%p1%03dX", []int{7}, "\033[007X"},
+	{"%p1%c", []int{'A'}, "A"},
+	{"%{2}%{3}%*%d", nil, "6"},
+	{"%%", nil, "%"},
+
+	// setaf of xterm-256color
+	{"\033[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m", []int{1}, "\033[31m"},
+	{"\033[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m", []int{9}, "\033[91m"},
+	{"\033[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m", []int{200}, "\033[38;5;200m"},
+}
+
+func TestTparm(t *testing.T) {
+	for _, tt := range tparmTests {
+		if got := tparm(tt.in, tt.params...); got != tt.out {
+			t.Errorf("tparm(%q, %v): got %q, want %q", tt.in, tt.params, got, tt.out)
+		}
+	}
+}
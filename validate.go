@@ -0,0 +1,94 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Checks an answer. The error is the message printed before of asking again,
+// after of QuestionErrPrefix.
+type Validator[T any] func(answer T) error
+
+type (
+	StringValidator = Validator[string]
+	IntValidator    = Validator[int]
+)
+
+// === Validators
+// ===
+
+// Checks that the answer is matched by the regular expression. If 'msg' is
+// empty, it is used a message with the expression.
+func MatchRegexp(re *regexp.Regexp, msg string) StringValidator {
+	return func(answer string) error {
+		if re.MatchString(answer) {
+			return nil
+		}
+		if msg == "" {
+			return fmt.Errorf("the value does not match %q", re.String())
+		}
+		return fmt.Errorf("%s", msg)
+	}
+}
+
+// Checks that the answer has 'n' characters at least.
+func MinLen(n int) StringValidator {
+	return func(answer string) error {
+		if utf8.RuneCountInString(answer) < n {
+			return fmt.Errorf("the value has to have %d characters at least", n)
+		}
+		return nil
+	}
+}
+
+// Checks that the answer has 'n' characters at most.
+func MaxLen(n int) StringValidator {
+	return func(answer string) error {
+		if utf8.RuneCountInString(answer) > n {
+			return fmt.Errorf("the value has to have %d characters at most", n)
+		}
+		return nil
+	}
+}
+
+// Checks that the answer is between 'min' and 'max', both included.
+func IntRange(min, max int) IntValidator {
+	return Range(min, max)
+}
+
+// Checks that the number is between 'min' and 'max', both included.
+func Range[T number](min, max T) Validator[T] {
+	return func(answer T) error {
+		if answer < min || answer > max {
+			return fmt.Errorf("the value has to be between %v and %v", min, max)
+		}
+		return nil
+	}
+}
+
+// ===
+
+// Returns the error of the first validator that fails.
+func validate[T any](answer T, valid []Validator[T]) error {
+	for _, v := range valid {
+		if err := v(answer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prints the message of a validator for the answer.
+func printInvalid(answer string, err error) {
+	fmt.Fprintf(output, "%s%q: %v\r\n", QuestionErrPrefix, answer, err)
+}
@@ -0,0 +1,58 @@
+// Copyright 2010  The "go-linoise" Authors
+//
+// Use of this source code is governed by the Simplified BSD License
+// that can be found in the LICENSE file.
+//
+// This software is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES
+// OR CONDITIONS OF ANY KIND, either express or implied. See the License
+// for more details.
+
+package linoise
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	name := []StringValidator{
+		MinLen(2),
+		MaxLen(4),
+		MatchRegexp(regexp.MustCompile(`^[a-z]+$`), ""),
+		func(s string) error {
+			if s == "root" {
+				return errors.New("the name is reserved")
+			}
+			return nil
+		},
+	}
+
+	for _, tt := range []struct {
+		answer string
+		ok     bool
+	}{
+		{"ana", true},
+		{"a", false},
+		{"abcde", false},
+		{"Ana", false},
+		{"root", false},
+		{"añá", false},
+	} {
+		if err := validate(tt.answer, name); (err == nil) != tt.ok {
+			t.Errorf("%q: got error %v", tt.answer, err)
+		}
+	}
+
+	port := []IntValidator{IntRange(1, 65535)}
+	if err := validate(80, port); err != nil {
+		t.Errorf("80: got error %v", err)
+	}
+	if err := validate(0, port); err == nil {
+		t.Error("0: expected error")
+	}
+
+	if err := MatchRegexp(regexp.MustCompile(`^\d+$`), "only digits")("a"); err == nil || err.Error() != "only digits" {
+		t.Errorf("message of MatchRegexp: got %v", err)
+	}
+}